@@ -2,30 +2,126 @@ package data
 
 import (
 	"encoding/json"
+	"fmt"
 	"os"
 	"path/filepath"
+	"terbox/internal/vt"
+	"time"
 )
 
 // Config holds application configuration
 type Config struct {
 	Shell       string            `json:"shell"`
 	Theme       string            `json:"theme"`
+	Prefix      string            `json:"prefix"` // Key entering command mode; empty disables it
 	KeyBindings map[string]string `json:"keybindings"`
+
+	// CommandKeyBindings apply to the key after the prefix
+	CommandKeyBindings map[string]string `json:"command_keybindings"`
+
+	// NewTab is how tabs opened with new_tab start; they run the shell
+	// when it has no command
+	NewTab SessionOptions `json:"new_tab"`
+
+	// Profiles are named ways to open tabs, picked from a menu. The
+	// default profile, if set, is used by new_tab instead of NewTab.
+	Profiles       []Profile `json:"profiles"`
+	DefaultProfile string    `json:"default_profile"`
+
+	// HostTitle mirrors the active tab's title in the title of the
+	// terminal terbox runs in
+	HostTitle bool `json:"host_title"`
+
+	// ShellIntegration makes bash, zsh and fish report prompts, commands
+	// and the working directory without changes to their startup files
+	ShellIntegration bool `json:"shell_integration"`
+
+	// ScrollbackLines is the number of lines each session keeps above its
+	// screen; 0 keeps none
+	ScrollbackLines int `json:"scrollback_lines"`
+
+	// ScrollbackSpill keeps lines beyond ScrollbackLines, compressed in an
+	// unlinked temporary file per session, even when ScrollbackLines is 0
+	ScrollbackSpill bool `json:"scrollback_spill"`
+
+	// InheritCwd starts new tabs in the working directory of the current
+	// tab unless their options give one
+	InheritCwd bool `json:"inherit_cwd"`
+
+	// CopyModeKeys is "vi" or "emacs", the style of the keys moving and
+	// selecting in copy mode
+	CopyModeKeys string `json:"copy_mode_keys"`
+
+	// GracePeriod is how long closing a session waits after SIGHUP, and
+	// again after SIGTERM, before escalating
+	GracePeriod Duration `json:"grace_period"`
+}
+
+// Duration is a time.Duration written as a string such as "2s" or "500ms"
+type Duration time.Duration
+
+// MarshalJSON implements json.Marshaler
+func (d Duration) MarshalJSON() ([]byte, error) {
+	return json.Marshal(time.Duration(d).String())
+}
+
+// UnmarshalJSON implements json.Unmarshaler
+func (d *Duration) UnmarshalJSON(b []byte) error {
+	var s string
+	if err := json.Unmarshal(b, &s); err != nil {
+		return err
+	}
+	v, err := time.ParseDuration(s)
+	if err != nil {
+		return err
+	}
+	*d = Duration(v)
+	return nil
 }
 
 // DefaultConfig returns default configuration
 func DefaultConfig() *Config {
 	return &Config{
-		Shell: "/bin/sh",
-		Theme: "default",
-		KeyBindings: map[string]string{
-			"new_tab":   "ctrl+t",
-			"close_tab": "ctrl+w",
-			"settings":  "ctrl+s",
-			"help":      "ctrl+h",
-			"next_tab":  "ctrl+right",
-			"prev_tab":  "ctrl+left",
-			"quit":      "ctrl+q",
+		Shell:            "/bin/sh",
+		Theme:            "default",
+		Prefix:           "ctrl+b",
+		ScrollbackLines:  vt.DefaultScrollback,
+		ShellIntegration: true,
+		InheritCwd:       true,
+		CopyModeKeys:     "vi",
+		GracePeriod:      Duration(2 * time.Second),
+		// Every key a shell or editor might use reaches the session; terbox
+		// is driven through the prefix unless keys are bound here
+		KeyBindings: map[string]string{},
+		CommandKeyBindings: map[string]string{
+			"new_tab":         "c",
+			"new_tab_command": "C",
+			"new_tab_profile": "P",
+			"close_tab":       "x",
+			"restart_tab":     "R",
+			"next_tab":        "right,l,n",
+			"prev_tab":        "left,h,p",
+			"scroll_up":       "pgup",
+			"scroll_down":     "pgdown",
+			"prev_prompt":     "up",
+			"next_prompt":     "down",
+			"search":          "/",
+			"search_all":      "F",
+			"copy_mode":       "[",
+			"settings":        "s",
+			"help":            "?",
+			"reload_config":   "r",
+			"detach":          "d",
+			"quit":            "q",
+			"select_tab_1":    "1",
+			"select_tab_2":    "2",
+			"select_tab_3":    "3",
+			"select_tab_4":    "4",
+			"select_tab_5":    "5",
+			"select_tab_6":    "6",
+			"select_tab_7":    "7",
+			"select_tab_8":    "8",
+			"select_tab_9":    "9",
 		},
 	}
 }
@@ -43,7 +139,8 @@ func GetConfigPath() (string, error) {
 	return filepath.Join(configDir, "config.json"), nil
 }
 
-// LoadConfig loads configuration from file, returns default if not found
+// LoadConfig loads configuration from file, returns default if not found.
+// A file that is not valid JSON is an error.
 func LoadConfig() (*Config, error) {
 	configPath, err := GetConfigPath()
 	if err != nil {
@@ -60,7 +157,7 @@ func LoadConfig() (*Config, error) {
 
 	config := DefaultConfig()
 	if err := json.Unmarshal(data, config); err != nil {
-		return DefaultConfig(), nil
+		return nil, fmt.Errorf("%s: %w", configPath, err)
 	}
 	return config, nil
 }
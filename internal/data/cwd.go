@@ -0,0 +1,55 @@
+package data
+
+import (
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Cwd returns the working directory of the session's program: the one it
+// last reported through OSC 7, or else the one /proc showed for it when
+// UpdateForeground last ran. It is "" when neither is known, such as for a
+// remote shell.
+func (ts *TerminalSession) Cwd() string {
+	if dir := localDir(ts.Screen.WorkingDir()); dir != "" {
+		return dir
+	}
+
+	ts.mu.RLock()
+	defer ts.mu.RUnlock()
+	return ts.cwd
+}
+
+// readCwdLocked reads the working directory of the session's program from
+// /proc, or returns "" if it cannot
+func (ts *TerminalSession) readCwdLocked() string {
+	if ts.Cmd == nil || ts.Cmd.Process == nil || ts.exit != nil {
+		return ""
+	}
+	dir, err := os.Readlink(fmt.Sprintf("/proc/%d/cwd", ts.Cmd.Process.Pid))
+	if err != nil {
+		return ""
+	}
+	return dir
+}
+
+// localDir returns the path of a file:// URL reported through OSC 7 if it
+// is on this host, or else ""
+func localDir(rawURL string) string {
+	if rawURL == "" {
+		return ""
+	}
+	u, err := url.Parse(rawURL)
+	if err != nil || u.Scheme != "file" || !filepath.IsAbs(u.Path) {
+		return ""
+	}
+	if u.Host != "" && u.Host != "localhost" {
+		host, err := os.Hostname()
+		if err != nil || !strings.EqualFold(u.Host, host) {
+			return ""
+		}
+	}
+	return u.Path
+}
@@ -0,0 +1,113 @@
+package data
+
+import (
+	"embed"
+	"io/fs"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+// integrationFS holds the shell integration scripts, which report prompts,
+// commands and the working directory through OSC 133 and OSC 7
+//
+//go:embed all:integration
+var integrationFS embed.FS
+
+var (
+	integrationOnce sync.Once
+	integrationPath string
+	integrationErr  error
+)
+
+// integrationDir returns the directory the integration scripts are
+// written to, writing them on first use
+func integrationDir() (string, error) {
+	integrationOnce.Do(func() {
+		cache, err := os.UserCacheDir()
+		if err != nil {
+			integrationErr = err
+			return
+		}
+		dir := filepath.Join(cache, "terbox", "shell-integration")
+		// Rewritten on every start so the scripts match this version
+		integrationErr = fs.WalkDir(integrationFS, "integration", func(path string, d fs.DirEntry, err error) error {
+			if err != nil {
+				return err
+			}
+			target := filepath.Join(dir, strings.TrimPrefix(path, "integration"))
+			if d.IsDir() {
+				return os.MkdirAll(target, 0700)
+			}
+			content, err := integrationFS.ReadFile(path)
+			if err != nil {
+				return err
+			}
+			return os.WriteFile(target, content, 0600)
+		})
+		integrationPath = dir
+	})
+	return integrationPath, integrationErr
+}
+
+// integrate makes cmd, which starts a session running opts, load the
+// integration script of bash, zsh or fish after the user's own startup
+// files. Other programs, shells given arguments and shells whose scripts
+// cannot be written are left alone.
+func integrate(cmd *exec.Cmd, opts SessionOptions) {
+	if len(opts.Command) != 1 {
+		return
+	}
+	shell := filepath.Base(opts.Command[0])
+	if shell != "bash" && shell != "zsh" && shell != "fish" {
+		return
+	}
+	dir, err := integrationDir()
+	if err != nil {
+		return
+	}
+
+	switch shell {
+	case "bash":
+		script := filepath.Join(dir, "bash", "terbox.bash")
+		if !opts.Login {
+			cmd.Args = []string{cmd.Args[0], "--rcfile", script}
+			break
+		}
+		// A login shell ignores --rcfile, but in POSIX mode it reads ENV.
+		// argv[0] stays "-bash", so it remains a login shell; the script
+		// leaves POSIX mode, restores ENV and reads the login files itself.
+		if orig, ok := lookupEnv(cmd.Env, "ENV"); ok {
+			cmd.Env = append(cmd.Env, "TERBOX_BASH_ENV="+orig)
+		}
+		cmd.Args = []string{cmd.Args[0], "--posix"}
+		cmd.Env = append(cmd.Env, "ENV="+script, "TERBOX_BASH_LOGIN=1")
+	case "zsh":
+		// zsh reads .zshenv from ZDOTDIR; the script restores the original
+		if orig, ok := lookupEnv(cmd.Env, "ZDOTDIR"); ok {
+			cmd.Env = append(cmd.Env, "TERBOX_ZDOTDIR="+orig)
+		}
+		cmd.Env = append(cmd.Env, "ZDOTDIR="+filepath.Join(dir, "zsh"))
+	case "fish":
+		// fish sources vendor_conf.d in each of XDG_DATA_DIRS
+		orig, ok := lookupEnv(cmd.Env, "XDG_DATA_DIRS")
+		if ok {
+			cmd.Env = append(cmd.Env, "TERBOX_XDG_DATA_DIRS="+orig)
+		} else {
+			orig = "/usr/local/share:/usr/share"
+		}
+		cmd.Env = append(cmd.Env, "XDG_DATA_DIRS="+dir+string(os.PathListSeparator)+orig)
+	}
+}
+
+// lookupEnv returns the last value of name in env
+func lookupEnv(env []string, name string) (string, bool) {
+	for i := len(env) - 1; i >= 0; i-- {
+		if value, ok := strings.CutPrefix(env[i], name+"="); ok {
+			return value, true
+		}
+	}
+	return "", false
+}
@@ -0,0 +1,70 @@
+# terbox shell integration for bash, loaded with --rcfile in place of
+# ~/.bashrc, or through ENV by a login shell started with --posix. It reads
+# the user's startup files, then reports prompts, commands and their exit
+# status (OSC 133) and the working directory (OSC 7).
+
+if [[ -n $TERBOX_BASH_LOGIN ]]; then
+    # POSIX mode only got bash to read this file; a login shell started
+    # that way reads no startup files, so read them here
+    set +o posix
+    if [[ -n ${TERBOX_BASH_ENV+x} ]]; then
+        export ENV=$TERBOX_BASH_ENV
+    else
+        unset ENV
+    fi
+    unset TERBOX_BASH_LOGIN TERBOX_BASH_ENV
+    [[ -r /etc/profile ]] && . /etc/profile
+    for __terbox_file in ~/.bash_profile ~/.bash_login ~/.profile; do
+        if [[ -r $__terbox_file ]]; then
+            . "$__terbox_file"
+            break
+        fi
+    done
+    unset __terbox_file
+else
+    [[ -r /etc/bash.bashrc ]] && . /etc/bash.bashrc
+    [[ -r ~/.bashrc ]] && . ~/.bashrc
+fi
+
+# Percent-encodes a path for a file:// URL
+__terbox_urlencode() {
+    local LC_ALL=C s=$1 out= c i
+    for ((i = 0; i < ${#s}; i++)); do
+        c=${s:i:1}
+        case $c in
+            [a-zA-Z0-9/._~-]) out+=$c ;;
+            *) printf -v c '%%%02X' "'$c"; out+=$c ;;
+        esac
+    done
+    printf '%s' "$out"
+}
+
+# Runs first before each prompt: reports the status of the last command
+# and the working directory
+__terbox_precmd() {
+    local ret=$?
+    if [[ -n $__terbox_ran ]]; then
+        printf '\e]133;D;%s\a' "$ret"
+    fi
+    __terbox_ran=1
+    printf '\e]7;file://%s%s\a' "$HOSTNAME" "$(__terbox_urlencode "$PWD")"
+    return $ret
+}
+
+# Runs last before each prompt, after anything that rewrites PS1, and marks
+# where the prompt and the command line begin
+__terbox_ps1() {
+    local ret=$?
+    if [[ $PS1 != *'133;A'* ]]; then
+        PS1='\[\e]133;A\a\]'$PS1'\[\e]133;B\a\]'
+    fi
+    return $ret
+}
+
+if [[ $(declare -p PROMPT_COMMAND 2>/dev/null) == 'declare -a'* ]]; then
+    PROMPT_COMMAND=(__terbox_precmd "${PROMPT_COMMAND[@]}" __terbox_ps1)
+else
+    PROMPT_COMMAND=__terbox_precmd${PROMPT_COMMAND:+$'\n'$PROMPT_COMMAND}$'\n'__terbox_ps1
+fi
+# PS0 is shown once a command line has been read, before it runs
+PS0=$PS0'\e]133;C\a'
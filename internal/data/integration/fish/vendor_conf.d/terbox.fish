@@ -0,0 +1,45 @@
+# terbox shell integration for fish, found through XDG_DATA_DIRS. It
+# reports prompts, commands and their exit status (OSC 133) and the working
+# directory (OSC 7).
+
+if set -q TERBOX_XDG_DATA_DIRS
+    set -gx XDG_DATA_DIRS $TERBOX_XDG_DATA_DIRS
+    set -e TERBOX_XDG_DATA_DIRS
+else
+    set -e XDG_DATA_DIRS
+end
+
+if status is-interactive
+    function __terbox_status
+        return $argv[1]
+    end
+
+    function __terbox_preexec --on-event fish_preexec
+        printf '\e]133;C\a'
+    end
+
+    function __terbox_postexec --on-event fish_postexec
+        printf '\e]133;D;%s\a' $status
+    end
+
+    function __terbox_pwd --on-variable PWD
+        printf '\e]7;file://%s%s\a' $hostname (string escape --style=url -- $PWD | string replace -a %2F /)
+    end
+    __terbox_pwd
+
+    # config.fish is read after this file, so wrap the prompt it defines
+    # when the first one is shown
+    function __terbox_init --on-event fish_prompt
+        functions -e __terbox_init
+        functions -q fish_prompt; and functions -c fish_prompt __terbox_user_prompt
+        function fish_prompt
+            set -l last_status $status
+            printf '\e]133;A\a'
+            if functions -q __terbox_user_prompt
+                __terbox_status $last_status
+                __terbox_user_prompt
+            end
+            printf '\e]133;B\a'
+        end
+    end
+end
@@ -0,0 +1,81 @@
+package data
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strings"
+)
+
+// SessionOptions describes the program a session runs and how it starts
+type SessionOptions struct {
+	Command []string          `json:"command,omitempty"`   // Program and arguments
+	Dir     string            `json:"cwd,omitempty"`       // Working directory; "~" is the home directory
+	Env     map[string]string `json:"env,omitempty"`       // Variables to set
+	Unset   []string          `json:"unset_env,omitempty"` // Variables to remove from terbox's environment
+	Title   string            `json:"title,omitempty"`     // Initial tab title
+	Login   bool              `json:"login,omitempty"`     // Start the program as a login shell
+	Color   string            `json:"color,omitempty"`     // Tab color, a 0-255 index or "#rrggbb"
+
+	// Integration loads terbox's shell integration into bash, zsh and fish,
+	// see Config.ShellIntegration
+	Integration bool `json:"-"`
+}
+
+// environ returns the environment for the session's program
+func (opts SessionOptions) environ() []string {
+	env := slices.DeleteFunc(os.Environ(), func(kv string) bool {
+		name, _, _ := strings.Cut(kv, "=")
+		return name == "TERM" || slices.Contains(opts.Unset, name)
+	})
+	env = append(env, "TERM=xterm-256color")
+
+	names := make([]string, 0, len(opts.Env))
+	for name := range opts.Env {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		env = append(env, name+"="+opts.Env[name])
+	}
+	return env
+}
+
+// dir returns the working directory with a leading "~" expanded
+func (opts SessionOptions) dir() (string, error) {
+	if opts.Dir != "~" && !strings.HasPrefix(opts.Dir, "~/") {
+		return opts.Dir, nil
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(home, opts.Dir[1:]), nil
+}
+
+// AddFlags defines the command line flags for the options, shared by
+// "terbox new" and the new tab prompt. The remaining arguments are the
+// command.
+func (opts *SessionOptions) AddFlags(fs *flag.FlagSet) {
+	fs.StringVar(&opts.Dir, "c", opts.Dir, "working directory")
+	fs.Func("e", "set an environment variable, NAME=VALUE", func(s string) error {
+		name, value, ok := strings.Cut(s, "=")
+		if !ok || name == "" {
+			return fmt.Errorf("invalid variable %q, want NAME=VALUE", s)
+		}
+		if opts.Env == nil {
+			opts.Env = make(map[string]string)
+		}
+		opts.Env[name] = value
+		return nil
+	})
+	fs.Func("u", "remove an environment variable", func(name string) error {
+		opts.Unset = append(opts.Unset, name)
+		return nil
+	})
+	fs.StringVar(&opts.Title, "t", opts.Title, "tab title")
+	fs.BoolVar(&opts.Login, "l", opts.Login, "start as a login shell")
+}
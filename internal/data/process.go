@@ -0,0 +1,260 @@
+package data
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+
+	"golang.org/x/sys/unix"
+)
+
+// terminatePollInterval is how often Terminate checks whether processes
+// have exited
+const terminatePollInterval = 20 * time.Millisecond
+
+// Process is a process running on a session's terminal
+type Process struct {
+	PID     int    `json:"pid"`
+	PGID    int    `json:"pgid"`
+	Command string `json:"command,omitempty"`
+}
+
+// String returns the PID followed by the command name, e.g. "1234 (node)"
+func (p Process) String() string {
+	if p.Command == "" {
+		return strconv.Itoa(p.PID)
+	}
+	return fmt.Sprintf("%d (%s)", p.PID, p.Command)
+}
+
+// SurvivorsError lists processes that were still running a grace period
+// after the hangup and had to be terminated
+type SurvivorsError struct {
+	Session   string
+	Processes []Process
+}
+
+func (e *SurvivorsError) Error() string {
+	names := make([]string, len(e.Processes))
+	for i, p := range e.Processes {
+		names[i] = p.String()
+	}
+	return fmt.Sprintf("%s: still running after hangup, terminated: %s", e.Session, strings.Join(names, ", "))
+}
+
+// Terminate ends every process on the session's terminal. Each process
+// group gets SIGHUP, as on a terminal hangup, then SIGTERM once grace has
+// passed and SIGKILL after another grace period. Processes that outlived
+// the hangup are reported in a *SurvivorsError.
+func (ts *TerminalSession) Terminate(grace time.Duration) error {
+	ts.mu.Lock()
+	if ts.Cmd == nil || ts.Cmd.Process == nil {
+		ts.mu.Unlock()
+		return ts.Close()
+	}
+	// The shell leads the terminal's session and its first process group.
+	// Without /proc the foreground group is the only other one known.
+	sid := ts.Cmd.Process.Pid
+	groups := map[int]bool{}
+	if pgrp, err := ts.foregroundGroupLocked(); err == nil && pgrp > 0 && pgrp != sid {
+		groups[pgrp] = true
+	}
+	ts.mu.Unlock()
+
+	var err error
+	ts.signalGroups(sid, groups, unix.SIGHUP)
+	if survivors := ts.waitProcesses(sid, groups, grace); len(survivors) > 0 {
+		err = &SurvivorsError{Session: ts.ID, Processes: survivors}
+		ts.signalGroups(sid, groups, unix.SIGTERM)
+		if len(ts.waitProcesses(sid, groups, grace)) > 0 {
+			ts.signalGroups(sid, groups, unix.SIGKILL)
+		}
+	}
+
+	ts.mu.Lock()
+	if ts.pty != nil {
+		ts.pty.Close()
+	}
+	ts.mu.Unlock()
+	return err
+}
+
+// foregroundGroupLocked returns the process group in the foreground of the
+// session's terminal
+func (ts *TerminalSession) foregroundGroupLocked() (int, error) {
+	if ts.pty == nil {
+		return 0, ErrSessionNotStarted
+	}
+	// Control keeps the file from being closed while the ioctl runs
+	rc, err := ts.pty.SyscallConn()
+	if err != nil {
+		return 0, err
+	}
+	var pgrp int
+	var ioctlErr error
+	if err := rc.Control(func(fd uintptr) {
+		pgrp, ioctlErr = unix.IoctlGetInt(int(fd), unix.TIOCGPGRP)
+	}); err != nil {
+		return 0, err
+	}
+	return pgrp, ioctlErr
+}
+
+// UpdateForeground looks up the command running in the foreground of the
+// session's terminal and the working directory of the session's program,
+// and reports whether either changed. The command is empty while the
+// session's own program, such as the shell, is in the foreground.
+func (ts *TerminalSession) UpdateForeground() bool {
+	ts.mu.Lock()
+	defer ts.mu.Unlock()
+
+	command := ""
+	if ts.Cmd != nil && ts.Cmd.Process != nil && ts.exit == nil {
+		pgrp, err := ts.foregroundGroupLocked()
+		if err == nil && pgrp > 0 && pgrp != ts.Cmd.Process.Pid {
+			command = commandLine(pgrp)
+		}
+	}
+	cwd := ts.readCwdLocked()
+	if command == ts.foreground && cwd == ts.cwd {
+		return false
+	}
+	ts.foreground, ts.cwd = command, cwd
+	return true
+}
+
+// Foreground returns the command line found by the last UpdateForeground
+func (ts *TerminalSession) Foreground() string {
+	ts.mu.RLock()
+	defer ts.mu.RUnlock()
+	return ts.foreground
+}
+
+// commandLine returns the command line of a process as typed, e.g.
+// "vim main.go", or "" if it cannot be read
+func commandLine(pid int) string {
+	cmdline, err := os.ReadFile(fmt.Sprintf("/proc/%d/cmdline", pid))
+	if err != nil {
+		return ""
+	}
+	args := strings.Split(strings.TrimRight(string(cmdline), "\x00"), "\x00")
+	if args[0] == "" {
+		return ""
+	}
+	args[0] = filepath.Base(args[0])
+	return sanitizeCommand(strings.Join(args, " "))
+}
+
+// sanitizeCommand drops control characters and invalid UTF-8
+func sanitizeCommand(s string) string {
+	s = strings.ToValidUTF8(s, "")
+	return strings.Map(func(r rune) rune {
+		if unicode.IsControl(r) {
+			return -1
+		}
+		return r
+	}, s)
+}
+
+// signalGroups sends sig to the process groups of the terminal session sid
+// that have processes running now. The ID of a group whose processes are
+// all gone may be reused, and so may the leader's PID once it has been
+// reaped, so no other group is signalled. Stopped jobs are continued so
+// they can act on the signal.
+func (ts *TerminalSession) signalGroups(sid int, groups map[int]bool, sig unix.Signal) {
+	live := make(map[int]bool)
+	for _, p := range ts.liveProcesses(sid, groups) {
+		live[p.PGID] = true
+	}
+	for pgrp := range live {
+		unix.Kill(-pgrp, sig)
+		if sig != unix.SIGKILL {
+			unix.Kill(-pgrp, unix.SIGCONT)
+		}
+	}
+}
+
+// waitProcesses waits up to grace for the processes of the terminal
+// session sid to exit and returns those still running
+func (ts *TerminalSession) waitProcesses(sid int, groups map[int]bool, grace time.Duration) []Process {
+	deadline := time.Now().Add(grace)
+	for {
+		live := ts.liveProcesses(sid, groups)
+		if len(live) == 0 || !time.Now().Before(deadline) {
+			return live
+		}
+		time.Sleep(terminatePollInterval)
+	}
+}
+
+// liveProcesses returns the running processes of the terminal session sid.
+// Where /proc is not available only process groups can be checked, the
+// leader's until it is reaped and the others in groups, and each live
+// group is reported by its ID.
+func (ts *TerminalSession) liveProcesses(sid int, groups map[int]bool) []Process {
+	if _, err := os.Stat("/proc/self/stat"); err == nil {
+		return sessionProcesses(sid)
+	}
+	var live []Process
+	if ts.IsAlive() {
+		live = append(live, Process{PID: sid, PGID: sid})
+	}
+	for pgrp := range groups {
+		if unix.Kill(-pgrp, 0) == nil {
+			live = append(live, Process{PID: pgrp, PGID: pgrp})
+		}
+	}
+	return live
+}
+
+// sessionProcesses lists the processes of the terminal session sid that
+// have not exited, using /proc. It returns nil where /proc is not available.
+func sessionProcesses(sid int) []Process {
+	entries, err := os.ReadDir("/proc")
+	if err != nil {
+		return nil
+	}
+	var procs []Process
+	for _, entry := range entries {
+		pid, err := strconv.Atoi(entry.Name())
+		if err != nil {
+			continue
+		}
+		stat, err := os.ReadFile(fmt.Sprintf("/proc/%d/stat", pid))
+		if err != nil {
+			continue
+		}
+		p, session, state, ok := parseStat(stat)
+		if ok && session == sid && state != 'Z' && state != 'X' {
+			procs = append(procs, p)
+		}
+	}
+	return procs
+}
+
+// parseStat parses /proc/<pid>/stat, "pid (comm) state ppid pgrp session ...".
+// The command name may itself contain spaces and parentheses.
+func parseStat(stat []byte) (p Process, session int, state byte, ok bool) {
+	open := bytes.IndexByte(stat, '(')
+	end := bytes.LastIndexByte(stat, ')')
+	if open < 0 || end < open {
+		return Process{}, 0, 0, false
+	}
+	fields := strings.Fields(string(stat[end+1:]))
+	if len(fields) < 4 || len(fields[0]) != 1 {
+		return Process{}, 0, 0, false
+	}
+	pid, err1 := strconv.Atoi(strings.TrimSpace(string(stat[:open])))
+	pgrp, err2 := strconv.Atoi(fields[2])
+	session, err3 := strconv.Atoi(fields[3])
+	if err1 != nil || err2 != nil || err3 != nil {
+		return Process{}, 0, 0, false
+	}
+	p = Process{PID: pid, PGID: pgrp, Command: string(stat[open+1 : end])}
+	return p, session, fields[0][0], true
+}
@@ -0,0 +1,87 @@
+package data
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// hexColor matches colors written as "#rgb" or "#rrggbb"
+var hexColor = regexp.MustCompile(`^#([0-9a-fA-F]{3}|[0-9a-fA-F]{6})$`)
+
+// Profile is a named way to open a tab, such as a dev server or a database
+// console
+type Profile struct {
+	Name string `json:"name"`
+	SessionOptions
+}
+
+// Options returns the options for a tab opened with the profile. The tab
+// is titled after the profile unless it sets a title.
+func (p Profile) Options() SessionOptions {
+	opts := p.SessionOptions
+	if opts.Title == "" {
+		opts.Title = p.Name
+	}
+	return opts
+}
+
+// Profile finds a profile by name
+func (c *Config) Profile(name string) (Profile, bool) {
+	for _, p := range c.Profiles {
+		if p.Name == name {
+			return p, true
+		}
+	}
+	return Profile{}, false
+}
+
+// ValidateProfiles checks the profiles and the default profile. Every
+// problem is reported, naming the entry it was found in.
+func (c *Config) ValidateProfiles() error {
+	var errs []error
+	seen := make(map[string]bool)
+	for i, p := range c.Profiles {
+		entry := fmt.Sprintf("profiles[%d]", i)
+		if p.Name != "" {
+			entry += fmt.Sprintf(" (%q)", p.Name)
+		}
+
+		switch {
+		case strings.TrimSpace(p.Name) == "":
+			errs = append(errs, fmt.Errorf("%s: missing name", entry))
+		case seen[p.Name]:
+			errs = append(errs, fmt.Errorf("%s: duplicate name", entry))
+		}
+		seen[p.Name] = true
+
+		if len(p.Command) > 0 && p.Command[0] == "" {
+			errs = append(errs, fmt.Errorf("%s: command: empty program name", entry))
+		}
+		for name := range p.Env {
+			if name == "" || strings.ContainsAny(name, "= ") {
+				errs = append(errs, fmt.Errorf("%s: env: invalid variable name %q", entry, name))
+			}
+		}
+		if p.Color != "" && !validColor(p.Color) {
+			errs = append(errs, fmt.Errorf("%s: color: %q is not a 0-255 index or #rrggbb", entry, p.Color))
+		}
+	}
+
+	if c.DefaultProfile != "" {
+		if _, ok := c.Profile(c.DefaultProfile); !ok {
+			errs = append(errs, fmt.Errorf("default_profile: unknown profile %q", c.DefaultProfile))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// validColor reports whether s is an ANSI 256 color index or a hex color
+func validColor(s string) bool {
+	if n, err := strconv.Atoi(s); err == nil {
+		return n >= 0 && n <= 255
+	}
+	return hexColor.MatchString(s)
+}
@@ -1,11 +1,17 @@
 package data
 
 import (
+	"fmt"
 	"io"
 	"os"
 	"os/exec"
+	"path/filepath"
 	"sync"
+	"syscall"
+	"terbox/internal/vt"
 	"time"
+
+	"github.com/creack/pty"
 )
 
 // TerminalSession represents a single terminal session
@@ -16,68 +22,162 @@ type TerminalSession struct {
 	Input       io.WriteCloser
 	Output      io.Reader
 	LastCommand string
+	Title       string // Fixed tab title, shown instead of the program's title
 	CreatedAt   time.Time
+	Screen      *vt.Screen // Emulated screen fed from Output
+	pty         *os.File
+	opts        SessionOptions
+	exit        *ExitStatus   // Set once the process has been reaped
+	foreground  string        // Command line of the foreground job, see UpdateForeground
+	cwd         string        // Working directory read from /proc, see UpdateForeground
+	exitCode    *int          // Exit status of the last command run at the prompt
+	done        chan struct{} // Closed when the process has been reaped
 	mu          sync.RWMutex
+
+	// View state: lines scrolled back into the scrollback, counted at the
+	// time scrollMark (the screen's scroll count) was taken
+	scrollOffset int
+	scrollMark   uint64
 }
 
 // NewTerminalSession creates a new terminal session
 func NewTerminalSession(id string, shell string) *TerminalSession {
-	return &TerminalSession{
+	ts := &TerminalSession{
 		ID:        id,
 		Name:      "shell",
 		CreatedAt: time.Now(),
+		Screen:    vt.NewScreen(80, 24),
+	}
+	ts.Screen.SetCommandFunc(ts.recordCommand)
+	return ts
+}
+
+// ExitStatus describes how a session's process ended
+type ExitStatus struct {
+	Code   int       `json:"code"`             // Exit code, -1 when killed by a signal
+	Signal string    `json:"signal,omitempty"` // Signal that killed the process, e.g. "killed"
+	Time   time.Time `json:"time"`             // When the process was reaped
+}
+
+// String describes the status like os.ProcessState, e.g. "exit status 1"
+// or "signal: killed"
+func (es ExitStatus) String() string {
+	if es.Signal != "" {
+		return "signal: " + es.Signal
 	}
+	return fmt.Sprintf("exit status %d", es.Code)
 }
 
-// Start starts the terminal session
-func (ts *TerminalSession) Start(shell string) error {
+// Start starts the session's program on a new pseudo-terminal
+func (ts *TerminalSession) Start(opts SessionOptions) error {
 	ts.mu.Lock()
 	defer ts.mu.Unlock()
 
-	cmd := exec.Command(shell)
-	cmd.Env = os.Environ()
-
-	stdin, err := cmd.StdinPipe()
-	if err != nil {
-		return err
+	if len(opts.Command) == 0 {
+		return ErrInvalidShell
 	}
-
-	stdout, err := cmd.StdoutPipe()
+	dir, err := opts.dir()
 	if err != nil {
 		return err
 	}
+	cmd := exec.Command(opts.Command[0], opts.Command[1:]...)
+	if opts.Login {
+		// A leading "-" in argv[0] makes shells read their login files
+		cmd.Args[0] = "-" + filepath.Base(opts.Command[0])
+	}
+	cmd.Dir = dir
+	cmd.Env = opts.environ()
+	if opts.Integration {
+		integrate(cmd, opts)
+	}
 
-	if err := cmd.Start(); err != nil {
+	// The child becomes a session leader with the PTY slave as its
+	// controlling terminal and as stdin, stdout and stderr.
+	cols, rows := ts.Screen.Size()
+	ptmx, err := pty.StartWithSize(cmd, &pty.Winsize{Rows: uint16(rows), Cols: uint16(cols)})
+	if err != nil {
 		return err
 	}
 
 	ts.Cmd = cmd
-	ts.Input = stdin
-	ts.Output = stdout
+	ts.pty = ptmx
+	ts.Input = ptmx
+	ts.Output = ptmx
+	ts.opts = opts
+	ts.Title = opts.Title
+	ts.exit = nil
+	ts.done = make(chan struct{})
+	go ts.wait(cmd, ts.done)
 	return nil
 }
 
-// WriteCommand writes a command to the session
-func (ts *TerminalSession) WriteCommand(command string) error {
+// wait reaps the process and records its exit status
+func (ts *TerminalSession) wait(cmd *exec.Cmd, done chan struct{}) {
+	cmd.Wait()
+
+	status := ExitStatus{Code: cmd.ProcessState.ExitCode(), Time: time.Now()}
+	if ws, ok := cmd.ProcessState.Sys().(syscall.WaitStatus); ok && ws.Signaled() {
+		status.Signal = ws.Signal().String()
+	}
+
+	ts.mu.Lock()
+	ts.exit = &status
+	ts.mu.Unlock()
+	close(done)
+}
+
+// Done returns a channel closed when the session's process has exited, or
+// nil if the session was never started
+func (ts *TerminalSession) Done() <-chan struct{} {
+	ts.mu.RLock()
+	defer ts.mu.RUnlock()
+	return ts.done
+}
+
+// ExitStatus returns how the process ended, or nil while it runs
+func (ts *TerminalSession) ExitStatus() *ExitStatus {
 	ts.mu.RLock()
 	defer ts.mu.RUnlock()
+	return ts.exit
+}
+
+// Options returns the options the session was started with
+func (ts *TerminalSession) Options() SessionOptions {
+	ts.mu.RLock()
+	defer ts.mu.RUnlock()
+	return ts.opts
+}
+
+// WriteCommand writes a command line to the session as if typed
+func (ts *TerminalSession) WriteCommand(command string) error {
+	ts.mu.Lock()
+	defer ts.mu.Unlock()
 
 	if ts.Input == nil {
 		return ErrSessionNotStarted
 	}
 
-	_, err := ts.Input.Write([]byte(command + "\n"))
-	if err != nil {
+	if _, err := ts.Input.Write([]byte(command + "\r")); err != nil {
 		return err
 	}
 
-	ts.mu.Lock()
 	ts.LastCommand = command
-	ts.mu.Unlock()
-
 	return nil
 }
 
+// WriteInput writes raw input, such as encoded keystrokes, to the session
+func (ts *TerminalSession) WriteInput(p []byte) error {
+	ts.mu.RLock()
+	defer ts.mu.RUnlock()
+
+	if ts.Input == nil {
+		return ErrSessionNotStarted
+	}
+
+	_, err := ts.Input.Write(p)
+	return err
+}
+
 // GetName returns the session name (tab name)
 func (ts *TerminalSession) GetName() string {
 	ts.mu.RLock()
@@ -92,6 +192,54 @@ func (ts *TerminalSession) SetName(name string) {
 	ts.Name = name
 }
 
+// GetTitle returns the tab title: the one the session was started with,
+// or else the command running in the foreground, or else the last one the
+// program set through an OSC sequence. It is "" if there is none.
+func (ts *TerminalSession) GetTitle() string {
+	ts.mu.RLock()
+	defer ts.mu.RUnlock()
+	switch {
+	case ts.Title != "":
+		return ts.Title
+	case ts.foreground != "":
+		return ts.foreground
+	default:
+		return ts.Screen.Title()
+	}
+}
+
+// SetTitle sets the tab title
+func (ts *TerminalSession) SetTitle(title string) {
+	ts.mu.Lock()
+	defer ts.mu.Unlock()
+	ts.Title = title
+}
+
+// recordCommand keeps track of the commands run at the shell prompt, as
+// reported by shell integration marks
+func (ts *TerminalSession) recordCommand(ev vt.CommandEvent) {
+	ts.mu.Lock()
+	defer ts.mu.Unlock()
+
+	if !ev.Finished {
+		if ev.Command != "" {
+			ts.LastCommand = ev.Command
+		}
+		ts.exitCode = nil
+		return
+	}
+	code := ev.ExitCode
+	ts.exitCode = &code
+}
+
+// LastExitCode returns the exit status of the last command run at the
+// prompt, or nil while it runs or if the shell does not report it
+func (ts *TerminalSession) LastExitCode() *int {
+	ts.mu.RLock()
+	defer ts.mu.RUnlock()
+	return ts.exitCode
+}
+
 // GetLastCommand returns the last command run
 func (ts *TerminalSession) GetLastCommand() string {
 	ts.mu.RLock()
@@ -99,16 +247,90 @@ func (ts *TerminalSession) GetLastCommand() string {
 	return ts.LastCommand
 }
 
+// Resize changes the size of the session's screen and PTY. The kernel
+// delivers SIGWINCH to the foreground process group of the PTY.
+func (ts *TerminalSession) Resize(cols, rows int) error {
+	if cols < 1 || rows < 1 {
+		return nil
+	}
+
+	// Resize the screen first so output drawn for the new size lands on it
+	ts.Screen.Resize(cols, rows)
+
+	ts.mu.RLock()
+	defer ts.mu.RUnlock()
+	if ts.pty == nil {
+		return nil
+	}
+	return pty.Setsize(ts.pty, &pty.Winsize{Rows: uint16(rows), Cols: uint16(cols)})
+}
+
+// ScrollOffset returns how many lines the view is scrolled back. The view
+// stays on the same content while new output pushes lines into the
+// scrollback.
+func (ts *TerminalSession) ScrollOffset() int {
+	ts.mu.RLock()
+	defer ts.mu.RUnlock()
+	return ts.scrollOffsetLocked()
+}
+
+func (ts *TerminalSession) scrollOffsetLocked() int {
+	if ts.scrollOffset == 0 {
+		return 0
+	}
+	offset := ts.scrollOffset + int(ts.Screen.ScrollCount()-ts.scrollMark)
+	return min(offset, ts.Screen.ScrollbackLen())
+}
+
+// SetScrollOffset scrolls the view to offset lines back into the scrollback
+func (ts *TerminalSession) SetScrollOffset(offset int) {
+	ts.mu.Lock()
+	defer ts.mu.Unlock()
+	ts.scrollOffset = min(max(offset, 0), ts.Screen.ScrollbackLen())
+	ts.scrollMark = ts.Screen.ScrollCount()
+}
+
+// ScrollBy scrolls the view back (positive) or forward (negative) by n lines
+func (ts *TerminalSession) ScrollBy(n int) {
+	ts.mu.Lock()
+	defer ts.mu.Unlock()
+	offset := ts.scrollOffsetLocked() + n
+	ts.scrollOffset = min(max(offset, 0), ts.Screen.ScrollbackLen())
+	ts.scrollMark = ts.Screen.ScrollCount()
+}
+
+// JumpToPrompt scrolls the view to the previous (dir < 0) or next (dir > 0)
+// shell prompt and reports whether there was one
+func (ts *TerminalSession) JumpToPrompt(dir int) bool {
+	ts.mu.Lock()
+	defer ts.mu.Unlock()
+	offset, ok := ts.Screen.PromptOffset(ts.scrollOffsetLocked(), dir)
+	if ok {
+		ts.scrollOffset = offset
+		ts.scrollMark = ts.Screen.ScrollCount()
+	}
+	return ok
+}
+
+// ScrollToLine scrolls the view to show a line, numbered as in vt.Match,
+// if it is not already in view
+func (ts *TerminalSession) ScrollToLine(line uint64) {
+	ts.mu.Lock()
+	defer ts.mu.Unlock()
+	ts.scrollOffset = ts.Screen.LineOffset(line, ts.scrollOffsetLocked())
+	ts.scrollMark = ts.Screen.ScrollCount()
+}
+
 // Close closes the terminal session
 func (ts *TerminalSession) Close() error {
 	ts.mu.Lock()
 	defer ts.mu.Unlock()
 
-	if ts.Input != nil {
-		ts.Input.Close()
+	if ts.pty != nil {
+		ts.pty.Close()
 	}
 
-	if ts.Cmd != nil && ts.Cmd.Process != nil {
+	if ts.Cmd != nil && ts.Cmd.Process != nil && ts.exit == nil {
 		return ts.Cmd.Process.Kill()
 	}
 
@@ -119,18 +341,5 @@ func (ts *TerminalSession) Close() error {
 func (ts *TerminalSession) IsAlive() bool {
 	ts.mu.RLock()
 	defer ts.mu.RUnlock()
-
-	if ts.Cmd == nil || ts.Cmd.Process == nil {
-		return false
-	}
-
-	// Try to find the process
-	proc, err := os.FindProcess(ts.Cmd.Process.Pid)
-	if err != nil {
-		return false
-	}
-
-	// Send signal 0 to check if process exists
-	err = proc.Signal(os.Signal(nil))
-	return err == nil
+	return ts.Cmd != nil && ts.exit == nil
 }
@@ -0,0 +1,151 @@
+package mux
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"terbox/internal/vt"
+)
+
+// CaptureFormat selects the output of CapturePane
+type CaptureFormat int
+
+const (
+	CapturePlain CaptureFormat = iota // text only
+	CaptureANSI                       // text with SGR sequences for colors and attributes
+	CaptureJSON                       // a Capture as JSON
+)
+
+// CaptureOptions selects what CapturePane returns
+type CaptureOptions struct {
+	Scrollback int // Lines of scrollback above the screen, -1 for all
+	Format     CaptureFormat
+	Join       bool // Join soft-wrapped lines (plain and ANSI formats)
+}
+
+// Capture is the JSON form of a captured pane
+type Capture struct {
+	ID      string         `json:"id"`
+	Cols    int            `json:"cols"`
+	Rows    int            `json:"rows"`
+	CursorX int            `json:"cursor_x"`
+	CursorY int            `json:"cursor_y"` // Row of the cursor within Lines
+	Lines   []CapturedLine `json:"lines"`
+}
+
+// CapturedLine is one row of a Capture. Cells stop at the last non-blank
+// cell, and the right halves of wide characters are left out.
+type CapturedLine struct {
+	Cells   []CapturedCell `json:"cells"`
+	Wrapped bool           `json:"wrapped,omitempty"`
+}
+
+// CapturedCell is one character of a CapturedLine. Colors are palette
+// indexes ("1", "208") or RGB values ("#ff8800"), omitted when default.
+type CapturedCell struct {
+	Char  string   `json:"char"`
+	Width int      `json:"width"`
+	Fg    string   `json:"fg,omitempty"`
+	Bg    string   `json:"bg,omitempty"`
+	Attrs []string `json:"attrs,omitempty"`
+}
+
+// attrNames names the attributes in CapturedCell.Attrs
+var attrNames = []struct {
+	attr vt.Attr
+	name string
+}{
+	{vt.AttrBold, "bold"},
+	{vt.AttrFaint, "faint"},
+	{vt.AttrItalic, "italic"},
+	{vt.AttrUnderline, "underline"},
+	{vt.AttrBlink, "blink"},
+	{vt.AttrReverse, "reverse"},
+	{vt.AttrInvisible, "invisible"},
+	{vt.AttrStrike, "strike"},
+}
+
+// CapturePane returns the visible screen of a session, preceded by lines
+// of its scrollback, in the requested format
+func (m *Multiplexer) CapturePane(id string, opts CaptureOptions) (string, error) {
+	session, err := m.GetSession(id)
+	if err != nil {
+		return "", err
+	}
+	screen := session.Screen
+	lines := screen.Capture(opts.Scrollback)
+
+	if opts.Format == CaptureJSON {
+		cols, rows := screen.Size()
+		x, y, _ := screen.Cursor()
+		capture := Capture{
+			ID:      id,
+			Cols:    cols,
+			Rows:    rows,
+			CursorX: x,
+			CursorY: len(lines) - rows + y,
+			Lines:   make([]CapturedLine, len(lines)),
+		}
+		for i, line := range lines {
+			capture.Lines[i] = captureLine(line)
+		}
+		data, err := json.Marshal(capture)
+		if err != nil {
+			return "", err
+		}
+		return string(data) + "\n", nil
+	}
+
+	var sb strings.Builder
+	for _, line := range lines {
+		if opts.Format == CaptureANSI {
+			sb.WriteString(line.ANSI())
+		} else {
+			sb.WriteString(line.String())
+		}
+		if !opts.Join || !line.Wrapped {
+			sb.WriteByte('\n')
+		}
+	}
+	return sb.String(), nil
+}
+
+// captureLine converts a line to its JSON form
+func captureLine(line vt.Line) CapturedLine {
+	n := line.Len()
+	cells := make([]CapturedCell, 0, n)
+	for _, cell := range line.Cells[:n] {
+		if cell.Width == 0 {
+			continue
+		}
+		char := " "
+		if cell.Rune != 0 {
+			char = string(cell.Rune)
+		}
+		captured := CapturedCell{
+			Char:  char,
+			Width: int(cell.Width),
+			Fg:    colorName(cell.Fg),
+			Bg:    colorName(cell.Bg),
+		}
+		for _, a := range attrNames {
+			if cell.Attr&a.attr != 0 {
+				captured.Attrs = append(captured.Attrs, a.name)
+			}
+		}
+		cells = append(cells, captured)
+	}
+	return CapturedLine{Cells: cells, Wrapped: line.Wrapped}
+}
+
+// colorName formats a color for CapturedCell
+func colorName(c vt.Color) string {
+	if idx, ok := c.Index(); ok {
+		return strconv.Itoa(int(idx))
+	}
+	if r, g, b, ok := c.RGB(); ok {
+		return fmt.Sprintf("#%02x%02x%02x", r, g, b)
+	}
+	return ""
+}
@@ -0,0 +1,31 @@
+package mux
+
+import (
+	"terbox/internal/data"
+	"time"
+)
+
+// foregroundInterval is how often sessions are checked for a change of the
+// command running in the foreground or of the working directory
+const foregroundInterval = 500 * time.Millisecond
+
+// watchForeground keeps the session's foreground command and working
+// directory up to date until done is closed, so tabs can show e.g.
+// "vim main.go" while it runs and views need not read /proc each time
+func (m *Multiplexer) watchForeground(session *data.TerminalSession, done <-chan struct{}) {
+	if session.UpdateForeground() {
+		m.markUpdated(session.ID)
+	}
+	ticker := time.NewTicker(foregroundInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-done:
+			return
+		case <-ticker.C:
+			if session.UpdateForeground() {
+				m.markUpdated(session.ID)
+			}
+		}
+	}
+}
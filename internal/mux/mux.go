@@ -1,9 +1,11 @@
 package mux
 
 import (
+	"errors"
 	"fmt"
 	"sync"
 	"terbox/internal/data"
+	"terbox/internal/vt"
 	"time"
 )
 
@@ -13,20 +15,90 @@ type Multiplexer struct {
 	order    []string
 	active   string
 	mu       sync.RWMutex
-	config   *data.Config
+	config   *data.Config // replaced by SetConfig, never changed in place
+	cols     int          // pane size given to sessions, 0 until known
+	rows     int
+
+	nextID int // number of the next session created by NewSession
+
+	pumps      map[string]chan struct{} // closed when a session's output goroutine ends
+	restarting map[string]struct{}      // sessions being restarted by RestartSession
+	subs       map[*Subscription]struct{}
+	subsMu     sync.Mutex
 }
 
 // NewMultiplexer creates a new multiplexer
 func NewMultiplexer(config *data.Config) *Multiplexer {
 	return &Multiplexer{
-		sessions: make(map[string]*data.TerminalSession),
-		order:    []string{},
-		config:   config,
+		sessions:   make(map[string]*data.TerminalSession),
+		order:      []string{},
+		config:     config,
+		nextID:     1,
+		pumps:      make(map[string]chan struct{}),
+		restarting: make(map[string]struct{}),
+		subs:       make(map[*Subscription]struct{}),
 	}
 }
 
-// CreateSession creates a new terminal session
-func (m *Multiplexer) CreateSession(id string) (*data.TerminalSession, error) {
+// Config returns the configuration. It is replaced rather than modified by
+// SetConfig, so it may be read without further locking.
+func (m *Multiplexer) Config() *data.Config {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return m.config
+}
+
+// SetConfig replaces the configuration and applies its scrollback settings
+// to the open sessions. The other settings apply to sessions created from
+// then on.
+func (m *Multiplexer) SetConfig(config *data.Config) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.config = config
+	for _, session := range m.sessions {
+		applyScrollback(session.Screen, config)
+	}
+}
+
+// applyScrollback sets a screen's scrollback limit and spill file as
+// configured. Turning the spill off drops the lines in it.
+func applyScrollback(screen *vt.Screen, config *data.Config) {
+	if config.ScrollbackSpill {
+		// Without a spill file the oldest lines are discarded as usual
+		screen.SpillScrollback()
+	} else {
+		screen.CloseSpill()
+	}
+	screen.SetScrollbackLimit(config.ScrollbackLines)
+}
+
+// NewSession creates a session with the next free ID, such as "session-3",
+// named after it ("shell-3")
+func (m *Multiplexer) NewSession(opts data.SessionOptions) (*data.TerminalSession, error) {
+	m.mu.Lock()
+	var id string
+	n := m.nextID
+	for {
+		id = fmt.Sprintf("session-%d", n)
+		n++
+		if _, exists := m.sessions[id]; !exists {
+			break
+		}
+	}
+	m.nextID = n
+	m.mu.Unlock()
+
+	session, err := m.CreateSession(id, opts)
+	if err != nil {
+		return nil, err
+	}
+	session.SetName(fmt.Sprintf("shell-%d", n-1))
+	return session, nil
+}
+
+// CreateSession creates a new terminal session. The configured shell runs
+// when opts has no command.
+func (m *Multiplexer) CreateSession(id string, opts data.SessionOptions) (*data.TerminalSession, error) {
 	m.mu.Lock()
 	defer m.mu.Unlock()
 
@@ -35,12 +107,21 @@ func (m *Multiplexer) CreateSession(id string) (*data.TerminalSession, error) {
 	}
 
 	session := data.NewTerminalSession(id, m.config.Shell)
-	if err := session.Start(m.config.Shell); err != nil {
+	applyScrollback(session.Screen, m.config)
+	if m.cols > 0 && m.rows > 0 {
+		session.Screen.Resize(m.cols, m.rows)
+	}
+	if len(opts.Command) == 0 {
+		opts.Command = []string{m.config.Shell}
+	}
+	opts.Integration = m.config.ShellIntegration
+	if err := session.Start(opts); err != nil {
 		return nil, err
 	}
 
 	m.sessions[id] = session
 	m.order = append(m.order, id)
+	m.startPump(session)
 
 	if m.active == "" {
 		m.active = id
@@ -49,6 +130,21 @@ func (m *Multiplexer) CreateSession(id string) (*data.TerminalSession, error) {
 	return session, nil
 }
 
+// Resize sets the pane size of every session, including those in
+// background tabs, and of sessions created later
+func (m *Multiplexer) Resize(cols, rows int) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if cols < 1 || rows < 1 || (cols == m.cols && rows == m.rows) {
+		return
+	}
+	m.cols, m.rows = cols, rows
+	for _, session := range m.sessions {
+		session.Resize(cols, rows)
+	}
+}
+
 // GetSession retrieves a session by ID
 func (m *Multiplexer) GetSession(id string) (*data.TerminalSession, error) {
 	m.mu.RLock()
@@ -61,20 +157,17 @@ func (m *Multiplexer) GetSession(id string) (*data.TerminalSession, error) {
 	return session, nil
 }
 
-// CloseSession closes a terminal session
+// CloseSession removes a session and ends its processes, giving them the
+// configured grace period to exit. Processes that had to be terminated are
+// reported in a *data.SurvivorsError.
 func (m *Multiplexer) CloseSession(id string) error {
 	m.mu.Lock()
-	defer m.mu.Unlock()
-
 	session, exists := m.sessions[id]
 	if !exists {
+		m.mu.Unlock()
 		return data.ErrSessionNotFound
 	}
 
-	if err := session.Close(); err != nil {
-		return err
-	}
-
 	delete(m.sessions, id)
 
 	// Remove from order
@@ -94,7 +187,85 @@ func (m *Multiplexer) CloseSession(id string) error {
 		}
 	}
 
-	return nil
+	pump := m.pumps[id]
+	delete(m.pumps, id)
+	grace := time.Duration(m.config.GracePeriod)
+	m.mu.Unlock()
+
+	// Let clients drop the tab while the processes shut down
+	m.markUpdated(id)
+	err := session.Terminate(grace)
+	// The output goroutine ends once no process holds the terminal open;
+	// closing the PTY does not interrupt a read in progress
+	if pump != nil {
+		<-pump
+	}
+	if spillErr := session.Screen.CloseSpill(); err == nil {
+		err = spillErr
+	}
+	return err
+}
+
+// RestartSession runs the command of an exited session again in place,
+// keeping its tab, name and screen contents. Processes the old program
+// left on the terminal are ended first, as when closing the tab, and
+// reported in a *data.SurvivorsError if the restart succeeds. Waiting for
+// them and starting the process happen without holding the lock, so the
+// other sessions and clients carry on meanwhile.
+func (m *Multiplexer) RestartSession(id string) error {
+	m.mu.Lock()
+	session, exists := m.sessions[id]
+	if !exists {
+		m.mu.Unlock()
+		return data.ErrSessionNotFound
+	}
+	if _, ok := m.restarting[id]; ok {
+		m.mu.Unlock()
+		return fmt.Errorf("session %s is already restarting", id)
+	}
+	if session.IsAlive() {
+		m.mu.Unlock()
+		return fmt.Errorf("session %s is still running", id)
+	}
+	m.restarting[id] = struct{}{}
+	pump := m.pumps[id]
+	delete(m.pumps, id)
+	grace := time.Duration(m.config.GracePeriod)
+	m.mu.Unlock()
+
+	// A background job left behind would hold the terminal open, and the
+	// output goroutine with it
+	termErr := session.Terminate(grace)
+	if pump != nil {
+		<-pump
+	}
+
+	// Undo modes the old program may have left set, such as the alternate
+	// screen or a hidden cursor
+	if session.Screen.Mode(vt.ModeAltScreen) {
+		session.Screen.Write([]byte("\x1b[?1049l"))
+	}
+	session.Screen.Write([]byte("\x1b[!p\r\n"))
+	err := session.Start(session.Options())
+
+	m.mu.Lock()
+	delete(m.restarting, id)
+	closed := m.sessions[id] != session
+	if err == nil && !closed {
+		m.startPump(session)
+	}
+	m.mu.Unlock()
+
+	if err == nil && closed {
+		// The tab was closed meanwhile, so the new process goes too
+		session.Terminate(grace)
+		return data.ErrSessionNotFound
+	}
+	m.markUpdated(id)
+	if err != nil {
+		return err
+	}
+	return termErr
 }
 
 // ListSessions returns all session IDs in order
@@ -207,6 +378,9 @@ func (m *Multiplexer) CleanupDeadSessions() {
 	}
 
 	for _, id := range deadSessions {
+		m.sessions[id].Close()
+		m.stopPump(id)
+		m.sessions[id].Screen.CloseSpill()
 		delete(m.sessions, id)
 
 		// Remove from order
@@ -240,17 +414,48 @@ func (m *Multiplexer) GetSessionInfo(id string) *SessionInfo {
 	return &SessionInfo{
 		ID:          id,
 		Name:        session.GetName(),
+		Title:       session.GetTitle(),
+		Foreground:  session.Foreground(),
+		Color:       session.Options().Color,
 		LastCommand: session.GetLastCommand(),
+		ExitCode:    session.LastExitCode(),
+		Cwd:         session.Cwd(),
 		CreatedAt:   session.CreatedAt,
 		IsAlive:     session.IsAlive(),
+		Exit:        session.ExitStatus(),
 	}
 }
 
 // SessionInfo contains metadata about a session
 type SessionInfo struct {
-	ID          string
-	Name        string
-	LastCommand string
-	CreatedAt   time.Time
-	IsAlive     bool
+	ID          string    `json:"id"`
+	Name        string    `json:"name"`
+	Title       string    `json:"title,omitempty"`
+	Foreground  string    `json:"foreground,omitempty"` // Command running in the foreground, if not the session's own
+	Color       string    `json:"color,omitempty"`
+	LastCommand string    `json:"last_command"`
+	ExitCode    *int      `json:"last_exit_code,omitempty"` // Of the last command, with shell integration
+	Cwd         string    `json:"cwd,omitempty"`            // Working directory, if known
+	CreatedAt   time.Time `json:"created_at"`
+	IsAlive     bool      `json:"alive"`
+
+	// Exit is how the process ended, nil while it is running
+	Exit *data.ExitStatus `json:"exit,omitempty"`
+}
+
+// CloseAll closes every session at once, so shutting down takes at most
+// two grace periods
+func (m *Multiplexer) CloseAll() error {
+	ids := m.ListSessions()
+	errs := make([]error, len(ids))
+	var wg sync.WaitGroup
+	for i, id := range ids {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			errs[i] = m.CloseSession(id)
+		}()
+	}
+	wg.Wait()
+	return errors.Join(errs...)
 }
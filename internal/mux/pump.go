@@ -0,0 +1,134 @@
+package mux
+
+import (
+	"fmt"
+	"sync"
+	"terbox/internal/data"
+)
+
+// pumpBufferSize is the size of the buffer used to read session output
+const pumpBufferSize = 32 * 1024
+
+// startPump starts the goroutine copying a session's output into its screen
+func (m *Multiplexer) startPump(session *data.TerminalSession) {
+	done := make(chan struct{})
+	m.pumps[session.ID] = done
+
+	// The process the pump was started for; a restart replaces the channel
+	exited := session.Done()
+
+	// Answers to terminal queries go back to the program
+	session.Screen.SetReplyFunc(func(reply []byte) {
+		session.Input.Write(reply)
+	})
+
+	go func(exited <-chan struct{}) {
+		defer close(done)
+		buf := make([]byte, pumpBufferSize)
+		for {
+			n, err := session.Output.Read(buf)
+			if n > 0 {
+				session.Screen.Write(buf[:n])
+				m.markUpdated(session.ID)
+			}
+			if err != nil {
+				// EOF or EIO once no process holds the terminal open
+				break
+			}
+		}
+
+		// Leave the exit status below the program's last output
+		<-exited
+		if status := session.ExitStatus(); status != nil {
+			fmt.Fprintf(session.Screen, "\r\n[process exited: %s]", status)
+		}
+		m.markUpdated(session.ID)
+	}(exited)
+
+	// Refresh the tab as soon as the process exits, even if a background
+	// job still holds the terminal open
+	go func(exited <-chan struct{}) {
+		<-exited
+		session.UpdateForeground()
+		m.markUpdated(session.ID)
+	}(exited)
+	go m.watchForeground(session, done)
+}
+
+// stopPump waits for the output goroutine of a closed session to finish
+func (m *Multiplexer) stopPump(id string) {
+	if done, ok := m.pumps[id]; ok {
+		<-done
+		delete(m.pumps, id)
+	}
+}
+
+// Subscription receives notifications of session output. Every attached
+// client has its own, so one client catching up does not hide output from
+// another.
+type Subscription struct {
+	updates chan struct{}
+	updated map[string]struct{}
+	mu      sync.Mutex
+}
+
+// Subscribe starts delivering output notifications to a new subscription
+func (m *Multiplexer) Subscribe() *Subscription {
+	sub := &Subscription{
+		updates: make(chan struct{}, 1),
+		updated: make(map[string]struct{}),
+	}
+	m.subsMu.Lock()
+	m.subs[sub] = struct{}{}
+	m.subsMu.Unlock()
+	return sub
+}
+
+// Unsubscribe stops notifications and closes the subscription's channel
+func (m *Multiplexer) Unsubscribe(sub *Subscription) {
+	m.subsMu.Lock()
+	defer m.subsMu.Unlock()
+
+	if _, ok := m.subs[sub]; ok {
+		delete(m.subs, sub)
+		close(sub.updates)
+	}
+}
+
+// markUpdated records that a session has new output and wakes up the
+// subscribers. Repeated output before a subscriber catches up is coalesced.
+func (m *Multiplexer) markUpdated(id string) {
+	m.subsMu.Lock()
+	defer m.subsMu.Unlock()
+
+	for sub := range m.subs {
+		sub.mu.Lock()
+		sub.updated[id] = struct{}{}
+		sub.mu.Unlock()
+
+		select {
+		case sub.updates <- struct{}{}:
+		default:
+		}
+	}
+}
+
+// Updates returns a channel that receives a value when sessions have new
+// output, and is closed by Unsubscribe. Use TakeUpdated to find out which
+// sessions changed.
+func (s *Subscription) Updates() <-chan struct{} {
+	return s.updates
+}
+
+// TakeUpdated returns the IDs of sessions with output since the last call
+func (s *Subscription) TakeUpdated() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	ids := make([]string, 0, len(s.updated))
+	for id := range s.updated {
+		ids = append(ids, id)
+	}
+	clear(s.updated)
+	return ids
+}
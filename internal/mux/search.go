@@ -0,0 +1,49 @@
+package mux
+
+import (
+	"regexp"
+	"terbox/internal/vt"
+)
+
+// SearchResult is a match of a search in a session: in its output, or in
+// its tab title when Title is set
+type SearchResult struct {
+	SessionID string
+	Title     bool
+	Match     vt.Match // Where in the output, for an output match
+	Text      string   // The title or the line of output that matched
+}
+
+// Search finds re in the tab title and in the screen and scrollback of
+// every session, in tab order. Of the output matches, at most limit per
+// session are returned, the newest ones; a limit of 0 or less returns all.
+func (m *Multiplexer) Search(re *regexp.Regexp, limit int) []SearchResult {
+	var results []SearchResult
+	for _, id := range m.ListSessions() {
+		session, err := m.GetSession(id)
+		if err != nil {
+			continue
+		}
+
+		title := session.GetTitle()
+		if title == "" {
+			title = session.GetName()
+		}
+		if re.MatchString(title) {
+			results = append(results, SearchResult{SessionID: id, Title: true, Text: title})
+		}
+
+		matches := session.Screen.Search(re)
+		if limit > 0 && len(matches) > limit {
+			matches = matches[len(matches)-limit:]
+		}
+		for _, match := range matches {
+			results = append(results, SearchResult{
+				SessionID: id,
+				Match:     match,
+				Text:      session.Screen.LineText(match.Line),
+			})
+		}
+	}
+	return results
+}
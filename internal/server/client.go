@@ -0,0 +1,120 @@
+package server
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"github.com/charmbracelet/x/term"
+)
+
+// Attach connects the terminal to the server on path until the client
+// detaches or the server exits. With start set, a server is started when
+// none is running.
+func Attach(path string, start bool) (Exit, error) {
+	stdin, stdout := os.Stdin.Fd(), os.Stdout.Fd()
+	if !term.IsTerminal(stdin) || !term.IsTerminal(stdout) {
+		return Exit{}, errors.New("attach needs a terminal")
+	}
+
+	nc, err := dial(path)
+	if errors.Is(err, ErrNoServer) && start {
+		nc, err = startServer(path)
+	}
+	if err != nil {
+		return Exit{}, err
+	}
+	defer nc.Close()
+	c := newConn(nc)
+
+	cols, rows, err := term.GetSize(stdout)
+	if err != nil {
+		return Exit{}, err
+	}
+	err = c.writeJSON(frameRequest, Request{
+		Command:   "attach",
+		Size:      Size{Cols: cols, Rows: rows},
+		Term:      os.Getenv("TERM"),
+		ColorTerm: os.Getenv("COLORTERM"),
+	})
+	if err != nil {
+		return Exit{}, err
+	}
+
+	state, err := term.MakeRaw(stdin)
+	if err != nil {
+		return Exit{}, err
+	}
+	defer term.Restore(stdin, state)
+
+	winch := make(chan os.Signal, 1)
+	signal.Notify(winch, syscall.SIGWINCH)
+	defer signal.Stop(winch)
+	go func() {
+		for range winch {
+			if cols, rows, err := term.GetSize(stdout); err == nil {
+				c.writeJSON(frameResize, Size{Cols: cols, Rows: rows})
+			}
+		}
+	}()
+
+	go func() {
+		buf := make([]byte, 4096)
+		for {
+			n, err := os.Stdin.Read(buf)
+			if n > 0 {
+				if c.writeFrame(frameInput, buf[:n]) != nil {
+					return
+				}
+			}
+			if err != nil {
+				return
+			}
+		}
+	}()
+
+	return receive(c, os.Stdout)
+}
+
+// Run sends a command to the server on path, copying its output to out,
+// and returns the exit status it reports. With start set, a server is
+// started when none is running.
+func Run(path string, out io.Writer, start bool, command string, args ...string) (Exit, error) {
+	nc, err := dial(path)
+	if errors.Is(err, ErrNoServer) && start {
+		nc, err = startServer(path)
+	}
+	if err != nil {
+		return Exit{}, err
+	}
+	defer nc.Close()
+	c := newConn(nc)
+
+	cwd, _ := os.Getwd()
+	if err := c.writeJSON(frameRequest, Request{Command: command, Args: args, Cwd: cwd}); err != nil {
+		return Exit{}, err
+	}
+	return receive(c, out)
+}
+
+// receive copies output frames to out until the exit frame arrives
+func receive(c *conn, out io.Writer) (Exit, error) {
+	for {
+		t, payload, err := c.readFrame()
+		if err != nil {
+			return Exit{}, fmt.Errorf("lost connection to server: %w", err)
+		}
+		switch t {
+		case frameOutput:
+			out.Write(payload)
+		case frameExit:
+			var exit Exit
+			err := json.Unmarshal(payload, &exit)
+			return exit, err
+		}
+	}
+}
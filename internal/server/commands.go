@@ -0,0 +1,288 @@
+package server
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"maps"
+	"path/filepath"
+	"slices"
+	"strings"
+	"terbox/internal/data"
+	"terbox/internal/mux"
+	"terbox/internal/ui"
+	"terbox/internal/vt"
+	"text/tabwriter"
+)
+
+// command runs a scripting request, writing its output to out
+type command func(s *Server, out io.Writer, req Request) Exit
+
+// commands are the requests other than attach
+var commands = map[string]command{
+	"new":          (*Server).newSession,
+	"ls":           (*Server).listSessions,
+	"kill":         (*Server).killSession,
+	"rename":       (*Server).renameSession,
+	"send-keys":    (*Server).sendKeys,
+	"capture-pane": (*Server).capturePane,
+	"detach":       (*Server).detach,
+	"kill-server":  (*Server).killServer,
+}
+
+// usageError reports invalid arguments
+func usageError(usage string) Exit {
+	return Exit{Code: ExitUsage, Message: "usage: terbox " + usage}
+}
+
+// failure reports a failed command
+func failure(err error) Exit {
+	return Exit{Code: ExitError, Message: err.Error()}
+}
+
+// newFlagSet creates a flag set that reports errors through the exit status
+func newFlagSet(name string) *flag.FlagSet {
+	fs := flag.NewFlagSet(name, flag.ContinueOnError)
+	fs.SetOutput(io.Discard)
+	return fs
+}
+
+// newSession implements "new [-n name] [-p profile] [options] [cmd...]"
+// and prints the new session's ID
+func (s *Server) newSession(out io.Writer, req Request) Exit {
+	const usage = "new [-n name] [-p profile] [-c dir] [-e NAME=VALUE] [-u NAME] [-t title] [-l] [command [args...]]"
+	var opts data.SessionOptions
+	fs, name, profile := newSessionFlags(&opts)
+	if err := fs.Parse(req.Args); err != nil {
+		return usageError(usage)
+	}
+
+	config := s.mux.Config()
+	profilesErr := config.ValidateProfiles()
+	if *profile != "" {
+		if profilesErr != nil {
+			return failure(profilesErr)
+		}
+		p, ok := config.Profile(*profile)
+		if !ok {
+			return failure(fmt.Errorf("unknown profile %q", *profile))
+		}
+		// Parse again on top of the profile, so flags override it
+		opts = p.Options()
+		opts.Env = maps.Clone(opts.Env)
+		opts.Unset = slices.Clone(opts.Unset)
+		fs, name, _ = newSessionFlags(&opts)
+		fs.Parse(req.Args)
+	}
+
+	if fs.NArg() > 0 {
+		opts.Command = fs.Args()
+	}
+	switch {
+	case opts.Dir == "":
+		opts.Dir = req.Cwd
+	case !filepath.IsAbs(opts.Dir) && !strings.HasPrefix(opts.Dir, "~"):
+		opts.Dir = filepath.Join(req.Cwd, opts.Dir)
+	}
+	session, err := s.mux.NewSession(opts)
+	if err != nil {
+		return failure(err)
+	}
+	if *name != "" {
+		session.SetName(*name)
+	}
+	fmt.Fprintln(out, session.ID)
+	if profilesErr != nil {
+		// The session does not need them, but say why profiles are missing
+		return Exit{Message: "profiles disabled: " + profilesErr.Error()}
+	}
+	return Exit{}
+}
+
+// newSessionFlags defines the flags of "new", setting opts
+func newSessionFlags(opts *data.SessionOptions) (fs *flag.FlagSet, name, profile *string) {
+	fs = newFlagSet("new")
+	name = fs.String("n", "", "session name")
+	profile = fs.String("p", "", "profile")
+	opts.AddFlags(fs)
+	return fs, name, profile
+}
+
+// listSessions implements "ls [-json]"
+func (s *Server) listSessions(out io.Writer, req Request) Exit {
+	fs := newFlagSet("ls")
+	asJSON := fs.Bool("json", false, "print JSON")
+	if fs.Parse(req.Args) != nil || fs.NArg() > 0 {
+		return usageError("ls [-json]")
+	}
+
+	infos := []*mux.SessionInfo{}
+	for _, id := range s.mux.ListSessions() {
+		if info := s.mux.GetSessionInfo(id); info != nil {
+			infos = append(infos, info)
+		}
+	}
+
+	if *asJSON {
+		if err := json.NewEncoder(out).Encode(infos); err != nil {
+			return failure(err)
+		}
+		return Exit{}
+	}
+
+	w := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "ID\tNAME\tCREATED\tSTATUS\tLAST COMMAND")
+	for _, info := range infos {
+		status := "running"
+		if info.Exit != nil {
+			status = info.Exit.String()
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\n", info.ID, info.Name,
+			info.CreatedAt.Format("2006-01-02 15:04:05"), status, info.LastCommand)
+	}
+	w.Flush()
+	return Exit{}
+}
+
+// killSession implements "kill <session>"
+func (s *Server) killSession(out io.Writer, req Request) Exit {
+	if len(req.Args) != 1 {
+		return usageError("kill <session>")
+	}
+	id, err := s.findSession(req.Args[0])
+	if err != nil {
+		return failure(err)
+	}
+	return closed(s.mux.CloseSession(id), "")
+}
+
+// renameSession implements "rename <session> <name>"
+func (s *Server) renameSession(out io.Writer, req Request) Exit {
+	if len(req.Args) != 2 || req.Args[1] == "" {
+		return usageError("rename <session> <name>")
+	}
+	id, err := s.findSession(req.Args[0])
+	if err != nil {
+		return failure(err)
+	}
+	session, err := s.mux.GetSession(id)
+	if err != nil {
+		return failure(err)
+	}
+	session.SetName(req.Args[1])
+	return Exit{}
+}
+
+// sendKeys implements "send-keys [-l] <session> <keys>...". Each argument
+// is a key named as in keybindings ("enter", "ctrl+c", "up"), or text
+// typed as is when it is not a key name or -l is given.
+func (s *Server) sendKeys(out io.Writer, req Request) Exit {
+	const usage = "send-keys [-l] <session> <keys>..."
+	fs := newFlagSet("send-keys")
+	literal := fs.Bool("l", false, "send keys as literal text")
+	if fs.Parse(req.Args) != nil || fs.NArg() < 2 {
+		return usageError(usage)
+	}
+	id, err := s.findSession(fs.Arg(0))
+	if err != nil {
+		return failure(err)
+	}
+	session, err := s.mux.GetSession(id)
+	if err != nil {
+		return failure(err)
+	}
+
+	appCursor := session.Screen.Mode(vt.ModeAppCursor)
+	var input []byte
+	for _, key := range fs.Args()[1:] {
+		if encoded, ok := ui.EncodeKeyName(key, appCursor); ok && !*literal {
+			input = append(input, encoded...)
+		} else {
+			input = append(input, key...)
+		}
+	}
+	if err := session.WriteInput(input); err != nil {
+		return failure(err)
+	}
+	return Exit{}
+}
+
+// capturePane implements "capture-pane [-n lines] [-e | -json] [-J]
+// <session>", printing the screen preceded by lines of scrollback
+func (s *Server) capturePane(out io.Writer, req Request) Exit {
+	const usage = "capture-pane [-n lines] [-e | -json] [-J] <session>"
+	fs := newFlagSet("capture-pane")
+	scrollback := fs.Int("n", 0, "lines of scrollback, -1 for all")
+	ansi := fs.Bool("e", false, "include colors and attributes as escape sequences")
+	asJSON := fs.Bool("json", false, "print cells as JSON")
+	join := fs.Bool("J", false, "join wrapped lines")
+	if fs.Parse(req.Args) != nil || fs.NArg() != 1 || (*ansi && *asJSON) {
+		return usageError(usage)
+	}
+	id, err := s.findSession(fs.Arg(0))
+	if err != nil {
+		return failure(err)
+	}
+
+	opts := mux.CaptureOptions{Scrollback: *scrollback, Join: *join}
+	switch {
+	case *ansi:
+		opts.Format = mux.CaptureANSI
+	case *asJSON:
+		opts.Format = mux.CaptureJSON
+	}
+	capture, err := s.mux.CapturePane(id, opts)
+	if err != nil {
+		return failure(err)
+	}
+	io.WriteString(out, capture)
+	return Exit{}
+}
+
+// detach implements "detach", detaching every attached client
+func (s *Server) detach(out io.Writer, req Request) Exit {
+	s.detachAll()
+	return Exit{}
+}
+
+// killServer implements "kill-server"
+func (s *Server) killServer(out io.Writer, req Request) Exit {
+	return closed(s.Shutdown(), "")
+}
+
+// closed reports the outcome of closing sessions. Processes that had to be
+// terminated are reported without failing the command.
+func closed(err error, message string) Exit {
+	var survivors *data.SurvivorsError
+	switch {
+	case err == nil:
+		return Exit{Message: message}
+	case errors.As(err, &survivors):
+		return Exit{Message: strings.TrimPrefix(message+"\n"+err.Error(), "\n")}
+	default:
+		return failure(err)
+	}
+}
+
+// findSession resolves a session given by ID or by its unique name
+func (s *Server) findSession(ref string) (string, error) {
+	if _, err := s.mux.GetSession(ref); err == nil {
+		return ref, nil
+	}
+	var found []string
+	for _, id := range s.mux.ListSessions() {
+		if session, err := s.mux.GetSession(id); err == nil && session.GetName() == ref {
+			found = append(found, id)
+		}
+	}
+	switch len(found) {
+	case 0:
+		return "", fmt.Errorf("session not found: %s", ref)
+	case 1:
+		return found[0], nil
+	default:
+		return "", fmt.Errorf("session name %q is ambiguous, use its ID", ref)
+	}
+}
@@ -0,0 +1,125 @@
+package server
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sync"
+)
+
+// frameType identifies the payload of a frame on the socket
+type frameType byte
+
+const (
+	frameRequest frameType = iota + 1 // client -> server: JSON Request, always first
+	frameInput                        // client -> server: bytes typed on the client's terminal
+	frameResize                       // client -> server: JSON Size
+	frameOutput                       // server -> client: bytes for the client's terminal or stdout
+	frameExit                         // server -> client: JSON Exit, always last
+)
+
+// Exit codes reported to clients
+const (
+	ExitOK    = 0 // success
+	ExitError = 1 // the command failed, e.g. an unknown session
+	ExitUsage = 2 // invalid arguments
+)
+
+// maxFrameSize bounds the payload of a single frame
+const maxFrameSize = 1 << 20
+
+// Request is the first message of every connection
+type Request struct {
+	Command   string   `json:"command"`
+	Args      []string `json:"args,omitempty"`
+	Cwd       string   `json:"cwd,omitempty"` // Relative paths in Args are relative to it
+	Size      Size     `json:"size"`
+	Term      string   `json:"term,omitempty"`
+	ColorTerm string   `json:"colorterm,omitempty"`
+}
+
+// Size is the size of a client's terminal
+type Size struct {
+	Cols int `json:"cols"`
+	Rows int `json:"rows"`
+}
+
+// Exit ends a connection with the exit status for the client
+type Exit struct {
+	Code    int    `json:"code"`
+	Message string `json:"message,omitempty"`
+}
+
+// conn sends and receives frames. Writes are serialized so output and the
+// final exit frame can come from different goroutines.
+type conn struct {
+	rw io.ReadWriter
+	mu sync.Mutex
+}
+
+// newConn wraps a connection in the frame protocol
+func newConn(rw io.ReadWriter) *conn {
+	return &conn{rw: rw}
+}
+
+// writeFrame sends one frame
+func (c *conn) writeFrame(t frameType, payload []byte) error {
+	if len(payload) > maxFrameSize {
+		return fmt.Errorf("frame too large: %d bytes", len(payload))
+	}
+	header := make([]byte, 5)
+	header[0] = byte(t)
+	binary.BigEndian.PutUint32(header[1:], uint32(len(payload)))
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if _, err := c.rw.Write(header); err != nil {
+		return err
+	}
+	_, err := c.rw.Write(payload)
+	return err
+}
+
+// writeJSON sends a frame with a JSON payload
+func (c *conn) writeJSON(t frameType, v any) error {
+	payload, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	return c.writeFrame(t, payload)
+}
+
+// readFrame receives one frame. It is not safe for concurrent use.
+func (c *conn) readFrame() (frameType, []byte, error) {
+	header := make([]byte, 5)
+	if _, err := io.ReadFull(c.rw, header); err != nil {
+		return 0, nil, err
+	}
+	n := binary.BigEndian.Uint32(header[1:])
+	if n > maxFrameSize {
+		return 0, nil, fmt.Errorf("frame too large: %d bytes", n)
+	}
+	payload := make([]byte, n)
+	if _, err := io.ReadFull(c.rw, payload); err != nil {
+		return 0, nil, err
+	}
+	return frameType(header[0]), payload, nil
+}
+
+// Write sends p as output frames, so conn can be a program's output
+func (c *conn) Write(p []byte) (int, error) {
+	for written := 0; written < len(p); {
+		n := min(len(p)-written, maxFrameSize)
+		if err := c.writeFrame(frameOutput, p[written:written+n]); err != nil {
+			return written, err
+		}
+		written += n
+	}
+	return len(p), nil
+}
+
+// exit sends the final frame of a connection
+func (c *conn) exit(code int, message string) error {
+	return c.writeJSON(frameExit, Exit{Code: code, Message: message})
+}
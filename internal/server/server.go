@@ -0,0 +1,268 @@
+package server
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"strings"
+	"sync"
+	"syscall"
+	"terbox/internal/data"
+	"terbox/internal/mux"
+	"terbox/internal/ui"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+	"github.com/muesli/termenv"
+)
+
+// Server owns the multiplexer and its PTYs and serves clients over a Unix
+// socket, so sessions outlive the terminals they are shown in
+type Server struct {
+	mux      *mux.Multiplexer // owns the configuration
+	path     string
+	listener net.Listener
+
+	mu       sync.Mutex
+	clients  map[*tea.Program]struct{}
+	closing  bool
+	done     chan struct{}
+	handlers sync.WaitGroup
+}
+
+// New creates a server with no sessions
+func New(config *data.Config) *Server {
+	return &Server{
+		mux:     mux.NewMultiplexer(config),
+		clients: make(map[*tea.Program]struct{}),
+		done:    make(chan struct{}),
+	}
+}
+
+// Listen creates the socket at path. A socket left behind by a server
+// that no longer runs is replaced.
+func (s *Server) Listen(path string) error {
+	if err := makeSocketDir(path); err != nil {
+		return err
+	}
+	if nc, err := dial(path); err == nil {
+		nc.Close()
+		return ErrServerRunning
+	}
+	os.Remove(path)
+
+	// The socket is created without access for others, so there is no
+	// moment when another user could connect. Nothing else runs yet to be
+	// affected by the process-wide umask.
+	umask := syscall.Umask(0077)
+	listener, err := net.Listen("unix", path)
+	syscall.Umask(umask)
+	if err != nil {
+		return err
+	}
+	s.path = path
+	s.listener = listener
+	return nil
+}
+
+// Serve accepts clients until the server shuts down
+func (s *Server) Serve() error {
+	defer s.handlers.Wait()
+	for {
+		nc, err := s.listener.Accept()
+		if err != nil {
+			s.mu.Lock()
+			closing := s.closing
+			s.mu.Unlock()
+			if !closing {
+				return err
+			}
+			// Shutdown closed the listener; wait until the sessions are gone
+			<-s.done
+			return nil
+		}
+		s.handlers.Add(1)
+		go func() {
+			defer s.handlers.Done()
+			s.handle(nc)
+		}()
+	}
+}
+
+// Shutdown closes every session, ends all clients and stops listening.
+// It reports processes that ignored the hangup and had to be terminated.
+func (s *Server) Shutdown() error {
+	s.mu.Lock()
+	if s.closing {
+		s.mu.Unlock()
+		return nil
+	}
+	s.closing = true
+	clients := make([]*tea.Program, 0, len(s.clients))
+	for p := range s.clients {
+		clients = append(clients, p)
+	}
+	s.mu.Unlock()
+
+	for _, p := range clients {
+		p.Quit()
+	}
+	// Stop accepting clients first; closing sessions can take a while
+	s.listener.Close()
+	os.Remove(s.path)
+	err := s.mux.CloseAll()
+	close(s.done)
+	return err
+}
+
+// handle serves one connection
+func (s *Server) handle(nc net.Conn) {
+	defer nc.Close()
+	c := newConn(nc)
+
+	t, payload, err := c.readFrame()
+	if err != nil || t != frameRequest {
+		return
+	}
+	var req Request
+	if err := json.Unmarshal(payload, &req); err != nil {
+		c.exit(ExitUsage, "invalid request")
+		return
+	}
+
+	if req.Command == "attach" {
+		s.attach(c, req)
+		return
+	}
+	run, ok := commands[req.Command]
+	if !ok {
+		c.exit(ExitUsage, fmt.Sprintf("unknown command %q", req.Command))
+		return
+	}
+	exit := run(s, c, req)
+	c.exit(exit.Code, exit.Message)
+}
+
+// attach runs the UI for a client until it detaches or quits
+func (s *Server) attach(c *conn, req Request) {
+	// Clients differ in colors, so each gets its own renderer rather than
+	// setting lipgloss's global profile
+	renderer := lipgloss.NewRenderer(c)
+	renderer.SetColorProfile(colorProfile(req.Term, req.ColorTerm))
+
+	input, inputWriter := io.Pipe()
+	app := ui.NewApp(s.mux.Config(), s.mux, renderer)
+	defer app.Close()
+	app.SetHostOutput(c)
+	p := tea.NewProgram(app,
+		tea.WithInput(input),
+		tea.WithOutput(c),
+		tea.WithAltScreen(),
+		tea.WithMouseCellMotion(),
+		tea.WithoutSignalHandler(),
+	)
+	if !s.addClient(p) {
+		c.exit(ExitError, "server is shutting down")
+		return
+	}
+
+	go func() {
+		defer inputWriter.Close()
+		// The program cannot query a socket for its size
+		p.Send(tea.WindowSizeMsg{Width: req.Size.Cols, Height: req.Size.Rows})
+		for {
+			t, payload, err := c.readFrame()
+			if err != nil {
+				// The client went away, e.g. its SSH connection dropped
+				p.Send(ui.DetachMsg{})
+				return
+			}
+			switch t {
+			case frameInput:
+				inputWriter.Write(payload)
+			case frameResize:
+				var size Size
+				if json.Unmarshal(payload, &size) == nil {
+					p.Send(tea.WindowSizeMsg{Width: size.Cols, Height: size.Rows})
+				}
+			}
+		}
+	}()
+
+	_, err := p.Run()
+	input.Close()
+	shuttingDown := s.removeClient(p)
+
+	switch {
+	case err != nil && !errors.Is(err, tea.ErrProgramKilled):
+		c.exit(ExitError, err.Error())
+	case shuttingDown:
+		c.exit(ExitOK, "server exited")
+	case app.Detached():
+		c.exit(ExitOK, "detached")
+		if s.idle() {
+			// Every session ended while the client was attached
+			s.Shutdown()
+		}
+	default:
+		exit := closed(s.Shutdown(), "exited")
+		c.exit(exit.Code, exit.Message)
+	}
+}
+
+// detachAll detaches every client
+func (s *Server) detachAll() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for p := range s.clients {
+		go p.Send(ui.DetachMsg{})
+	}
+}
+
+// addClient registers an attached program, unless the server is closing
+func (s *Server) addClient(p *tea.Program) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.closing {
+		return false
+	}
+	s.clients[p] = struct{}{}
+	return true
+}
+
+// removeClient unregisters a program and reports whether the server is
+// shutting down
+func (s *Server) removeClient(p *tea.Program) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	delete(s.clients, p)
+	return s.closing
+}
+
+// idle reports whether no client is attached and no session is left
+func (s *Server) idle() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return len(s.clients) == 0 && s.mux.SessionCount() == 0
+}
+
+// colorProfile picks the color profile of a client's terminal, since the
+// server's own output is not a terminal
+func colorProfile(term, colorTerm string) termenv.Profile {
+	switch {
+	case colorTerm == "truecolor" || colorTerm == "24bit":
+		return termenv.TrueColor
+	case strings.Contains(term, "256color"):
+		return termenv.ANSI256
+	case term == "" || term == "dumb":
+		return termenv.Ascii
+	default:
+		return termenv.ANSI
+	}
+}
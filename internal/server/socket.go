@@ -0,0 +1,117 @@
+package server
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"net"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"syscall"
+	"time"
+)
+
+// ErrNoServer is returned when no server is listening on the socket
+var ErrNoServer = errors.New("no terbox server running")
+
+// ErrServerRunning is returned when starting a second server on a socket
+var ErrServerRunning = errors.New("a terbox server is already running")
+
+// startTimeout is how long to wait for a newly started server's socket
+const startTimeout = 5 * time.Second
+
+// SocketPath returns the server socket: $TERBOX_SOCKET if set, otherwise
+// terbox/default.sock in $XDG_RUNTIME_DIR, or in a private directory under
+// the temp dir when XDG_RUNTIME_DIR is unset
+func SocketPath() string {
+	if path := os.Getenv("TERBOX_SOCKET"); path != "" {
+		return path
+	}
+	return filepath.Join(socketDir(), "default.sock")
+}
+
+// socketDir returns the directory of the default socket
+func socketDir() string {
+	if dir := os.Getenv("XDG_RUNTIME_DIR"); dir != "" {
+		return filepath.Join(dir, "terbox")
+	}
+	return filepath.Join(os.TempDir(), fmt.Sprintf("terbox-%d", os.Getuid()))
+}
+
+// makeSocketDir creates the directory of a socket. The default directory
+// must be private, as another user could create it first, e.g. under /tmp,
+// and own the socket in it. A socket set with TERBOX_SOCKET is up to the
+// user, like tmux -S.
+func makeSocketDir(path string) error {
+	dir := filepath.Dir(path)
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return err
+	}
+	if dir != socketDir() {
+		return nil
+	}
+	return checkPrivateDir(dir)
+}
+
+// checkPrivateDir returns an error unless dir is a directory, not a
+// symlink, owned by the current user and with mode 0700
+func checkPrivateDir(dir string) error {
+	fi, err := os.Lstat(dir)
+	if err != nil {
+		return err
+	}
+	st, ok := fi.Sys().(*syscall.Stat_t)
+	switch {
+	case !fi.IsDir():
+		return fmt.Errorf("socket directory %s is not a directory", dir)
+	case !ok || int(st.Uid) != os.Getuid():
+		return fmt.Errorf("socket directory %s is not owned by the current user", dir)
+	case fi.Mode().Perm() != 0700:
+		return fmt.Errorf("socket directory %s has mode %o, not 700", dir, fi.Mode().Perm())
+	}
+	return nil
+}
+
+// dial connects to the server socket. A default socket in a directory that
+// is not private is refused rather than trusted.
+func dial(path string) (net.Conn, error) {
+	if dir := filepath.Dir(path); dir == socketDir() {
+		if err := checkPrivateDir(dir); err != nil && !errors.Is(err, fs.ErrNotExist) {
+			return nil, err
+		}
+	}
+	nc, err := net.Dial("unix", path)
+	if err != nil {
+		return nil, ErrNoServer
+	}
+	return nc, nil
+}
+
+// startServer runs "terbox server" in the background, in its own session
+// so it outlives the terminal, and waits until it accepts connections
+func startServer(path string) (net.Conn, error) {
+	exe, err := os.Executable()
+	if err != nil {
+		return nil, err
+	}
+	// The server finds the same socket from the inherited environment
+	cmd := exec.Command(exe, "server")
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setsid: true}
+	if err := cmd.Start(); err != nil {
+		return nil, fmt.Errorf("starting server: %w", err)
+	}
+	cmd.Process.Release()
+
+	deadline := time.Now().Add(startTimeout)
+	for {
+		nc, err := dial(path)
+		if err == nil {
+			return nc, nil
+		}
+		if time.Now().After(deadline) {
+			return nil, fmt.Errorf("server did not start on %s", path)
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+}
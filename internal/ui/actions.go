@@ -0,0 +1,61 @@
+package ui
+
+import (
+	"fmt"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// action is a command that can be bound to keys in the config
+type action struct {
+	name        string
+	description string
+	run         func(a *App) tea.Cmd
+}
+
+// actions lists every bindable action in the order shown in help
+var actions []action
+
+// init fills in actions. It cannot be a variable initializer because
+// reload_config refers back to the action table through NewKeymap.
+func init() {
+	actions = []action{
+		{"new_tab", "Create new terminal tab", func(a *App) tea.Cmd { return a.createNewSession() }},
+		{"new_tab_command", "Open a tab running a command", func(a *App) tea.Cmd { a.prompt = newTabPrompt(); return nil }},
+		{"new_tab_profile", "Open a tab from a profile", func(a *App) tea.Cmd { return a.openProfileMenu() }},
+		{"close_tab", "Close current tab", func(a *App) tea.Cmd { return a.closeCurrentSession() }},
+		{"restart_tab", "Restart the exited command in the current tab", func(a *App) tea.Cmd { return a.restartCurrentSession() }},
+		{"next_tab", "Switch to next tab", func(a *App) tea.Cmd { a.tabBar.NextTab(); return nil }},
+		{"prev_tab", "Switch to previous tab", func(a *App) tea.Cmd { a.tabBar.PrevTab(); return nil }},
+		{"scroll_up", "Scroll up through history", func(a *App) tea.Cmd { a.terminal.scrollUp(); return nil }},
+		{"scroll_down", "Scroll down through history", func(a *App) tea.Cmd { a.terminal.scrollDown(); return nil }},
+		{"prev_prompt", "Scroll back to the previous prompt", func(a *App) tea.Cmd { a.terminal.jumpToPrompt(-1); return nil }},
+		{"next_prompt", "Scroll forward to the next prompt", func(a *App) tea.Cmd { a.terminal.jumpToPrompt(1); return nil }},
+		{"search", "Search the scrollback of the current tab", func(a *App) tea.Cmd { return a.openSearch() }},
+		{"search_all", "Search the titles and scrollback of all tabs", func(a *App) tea.Cmd { a.globalSearch = &globalSearch{}; return nil }},
+		{"copy_mode", "Select text with the keyboard and copy it", func(a *App) tea.Cmd { return a.openCopyMode() }},
+		{"settings", "Open settings", func(a *App) tea.Cmd { a.settingsMode = !a.settingsMode; return nil }},
+		{"help", "Show help", func(a *App) tea.Cmd { a.helpMode = !a.helpMode; return nil }},
+		{"reload_config", "Reload configuration", func(a *App) tea.Cmd { return a.reloadConfig() }},
+		{"detach", "Detach, leaving sessions running", func(a *App) tea.Cmd { a.detached = true; return tea.Quit }},
+		{"quit", "Quit terbox and close all sessions", func(a *App) tea.Cmd { return tea.Quit }},
+	}
+	for i := 1; i <= 9; i++ {
+		idx := i - 1
+		actions = append(actions, action{
+			name:        fmt.Sprintf("select_tab_%d", i),
+			description: fmt.Sprintf("Jump to tab %d", i),
+			run:         func(a *App) tea.Cmd { a.tabBar.SelectTab(idx); return nil },
+		})
+	}
+}
+
+// lookupAction finds an action by name
+func lookupAction(name string) (action, bool) {
+	for _, act := range actions {
+		if act.name == name {
+			return act, true
+		}
+	}
+	return action{}, false
+}
@@ -1,13 +1,21 @@
 package ui
 
 import (
+	"errors"
 	"fmt"
+	"io"
+	"os"
+	"slices"
+	"strings"
 	"terbox/internal/data"
 	"terbox/internal/mux"
 	"time"
+	"unicode/utf8"
 
+	"github.com/aymanbagabas/go-osc52/v2"
 	tea "github.com/charmbracelet/bubbletea"
 	"github.com/charmbracelet/lipgloss"
+	"github.com/mattn/go-runewidth"
 )
 
 // App represents the main application
@@ -15,28 +23,59 @@ type App struct {
 	multiplexer  *mux.Multiplexer
 	tabBar       *TabBar
 	terminal     *Terminal
+	output       *outputListener
 	config       *data.Config
 	theme        *Theme
 	width        int
 	height       int
-	sessionID    int
+	keymap       *Keymap
+	keymapErr    error // Why the configured keybindings were rejected
+	profilesErr  error // Why the configured profiles were rejected
+	profileMenu  *profileMenu
+	hostTitle    string // Title last set on the host terminal
+	mode         InputMode
+	pending      []tea.KeyMsg // Keys of a key sequence typed so far
+	notice       string       // Shown under the tab bar until the next key
+	prompt       *prompt      // Text input taking the keyboard, if open
+	search       *search      // Search in the active session, if open
+	globalSearch *globalSearch
+	copy         *copyMode // Copy mode in the active session, if open
+	hostOutput   io.Writer // Terminal the app is shown on, for OSC 52
+	detached     bool
 	helpMode     bool
 	settingsMode bool
 }
 
-// NewApp creates a new application
-func NewApp(config *data.Config) *App {
-	m := mux.NewMultiplexer(config)
-	return &App{
-		multiplexer:  m,
-		tabBar:       NewTabBarWithMux(m),
-		terminal:     NewTerminal(),
-		config:       config,
-		theme:        DefaultTheme(),
-		sessionID:    1,
-		helpMode:     false,
-		settingsMode: false,
+// NewApp creates a new application showing the sessions of a multiplexer.
+// Several apps can share one multiplexer, one per attached client, each
+// styled by the renderer for its client's terminal.
+func NewApp(config *data.Config, m *mux.Multiplexer, renderer *lipgloss.Renderer) *App {
+	theme := DefaultTheme()
+	theme.SetRenderer(renderer)
+	a := &App{
+		multiplexer: m,
+		tabBar:      NewTabBarWithMux(m, theme),
+		terminal:    NewTerminal(),
+		output:      &outputListener{sub: m.Subscribe()},
+		theme:       theme,
+		helpMode:    false,
 	}
+	a.applyConfig(config)
+	// Show what is wrong with the config
+	a.settingsMode = a.keymapErr != nil || a.profilesErr != nil
+	return a
+}
+
+// applyConfig switches the app to a configuration. Invalid keybindings
+// fall back to the defaults and invalid profiles are disabled rather than
+// holding up the rest.
+func (a *App) applyConfig(config *data.Config) {
+	keymap, err := NewKeymap(config)
+	if err != nil {
+		keymap = DefaultKeymap()
+	}
+	a.config, a.keymap, a.keymapErr = config, keymap, err
+	a.profilesErr = config.ValidateProfiles()
 }
 
 // Init initializes the app
@@ -48,57 +87,108 @@ func (a *App) Init() tea.Cmd {
 	if a.terminal != nil {
 		cmds = append(cmds, a.terminal.Init())
 	}
-	// create first session
-	cmds = append(cmds, a.createNewSession())
+	// create first session unless attaching to existing ones
+	if a.multiplexer.SessionCount() == 0 {
+		cmds = append(cmds, a.createNewSession())
+	}
+	cmds = append(cmds, a.output.listen())
 	return tea.Batch(cmds...)
 }
 
 // Update handles all messages
 func (a *App) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
+	model, cmd := a.update(msg)
+	if title := a.hostTitleCmd(); title != nil {
+		cmd = tea.Batch(cmd, title)
+	}
+	return model, cmd
+}
+
+// hostTitleCmd sets the host terminal's title to the active tab's title
+// when it has changed and mirroring is enabled
+func (a *App) hostTitleCmd() tea.Cmd {
+	if !a.config.HostTitle {
+		return nil
+	}
+	title := "terbox"
+	if info := a.multiplexer.GetSessionInfo(a.tabBar.GetActiveSessionID()); info != nil {
+		name := info.Name
+		if info.Title != "" {
+			name = info.Title
+		}
+		title = name + " - terbox"
+	}
+	if title == a.hostTitle {
+		return nil
+	}
+	a.hostTitle = title
+	return tea.SetWindowTitle(title)
+}
+
+func (a *App) update(msg tea.Msg) (tea.Model, tea.Cmd) {
 	var cmds []tea.Cmd
 
+	// Another client may have reloaded the configuration
+	if config := a.multiplexer.Config(); config != a.config {
+		a.applyConfig(config)
+	}
+
 	switch msg := msg.(type) {
 	case tea.WindowSizeMsg:
 		a.width = msg.Width
 		a.height = msg.Height
-
-		// Update component sizes
-		if a.tabBar != nil {
-			a.tabBar.SetSize(a.width, 1)
-		}
-		if a.terminal != nil {
-			a.terminal.SetSize(a.width, a.height-2)
-		}
+		a.layout()
+		a.showActiveSession()
+		return a, nil
 
 	case tea.KeyMsg:
-		switch msg.String() {
-		case "ctrl+q":
-			return a, tea.Quit
-		case "ctrl+t":
-			cmds = append(cmds, a.createNewSession())
-		case "ctrl+w":
-			cmds = append(cmds, a.closeCurrentSession())
-		case "ctrl+s":
-			a.settingsMode = !a.settingsMode
-		case "ctrl+h":
-			a.helpMode = !a.helpMode
-		case "ctrl+right":
-			if a.tabBar != nil {
-				a.tabBar.NextTab()
-			}
-		case "ctrl+left":
-			if a.tabBar != nil {
-				a.tabBar.PrevTab()
-			}
-		case "1", "2", "3", "4", "5", "6", "7", "8", "9":
-			idx := int(msg.String()[0]-'0') - 1
-			if a.tabBar != nil {
-				a.tabBar.SelectTab(idx)
-			}
+		a.notice = ""
+		switch {
+		case a.helpMode || a.settingsMode:
+			// Any key closes the screen rather than typing blind
+			a.helpMode, a.settingsMode = false, false
+		case a.prompt != nil:
+			cmds = append(cmds, a.handlePromptKey(msg))
+		case a.search != nil:
+			a.handleSearchKey(msg)
+		case a.globalSearch != nil:
+			cmds = append(cmds, a.handleGlobalSearchKey(msg))
+		case a.copy != nil:
+			a.handleCopyKey(msg)
+		case a.profileMenu != nil:
+			cmds = append(cmds, a.handleProfileMenuKey(msg))
+		default:
+			cmds = append(cmds, a.handleKey(msg))
 		}
+		a.showActiveSession()
+		return a, tea.Batch(cmds...)
 
 	case SessionUpdatedMsg:
 		// Update tab when session changes
+
+	case TabClosedMsg:
+		if msg.Err != nil && !errors.Is(msg.Err, data.ErrSessionNotFound) {
+			a.notice = msg.Err.Error()
+		}
+
+	case globalSearchMsg:
+		// Search once the query has stopped changing
+		if g := a.globalSearch; g == msg.search && g.query == msg.query {
+			g.run(a.multiplexer)
+		}
+
+	case TabRestartedMsg:
+		if msg.Err != nil && !errors.Is(msg.Err, data.ErrSessionNotFound) {
+			a.notice = "restart: " + msg.Err.Error()
+		}
+
+	case DetachMsg:
+		a.detached = true
+		return a, tea.Quit
+
+	case SessionOutputMsg:
+		// Keep listening; the view re-renders with the new output
+		cmds = append(cmds, a.output.listen())
 	}
 
 	// Delegate updates to components (guard nil)
@@ -107,11 +197,243 @@ func (a *App) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 	}
 	if a.terminal != nil {
 		a.terminal.Update(msg)
+		a.showActiveSession()
 	}
 
 	return a, tea.Batch(cmds...)
 }
 
+// Detached reports whether the app quit by detaching, as opposed to
+// quitting terbox
+func (a *App) Detached() bool {
+	return a.detached
+}
+
+// Close stops the app's output notifications once its program has ended
+func (a *App) Close() {
+	a.multiplexer.Unsubscribe(a.output.sub)
+}
+
+// handleKey runs a key through the input mode state machine and the keymap
+func (a *App) handleKey(msg tea.KeyMsg) tea.Cmd {
+	mode := a.mode
+	prefix := a.keymap.Prefix()
+	if mode == PassthroughMode && len(a.pending) == 0 && prefix != "" && msg.String() == prefix {
+		a.mode = CommandMode
+		return nil
+	}
+
+	a.pending = append(a.pending, msg)
+	keys := make([]string, len(a.pending))
+	for i, k := range a.pending {
+		keys[i] = k.String()
+	}
+	name, partial := a.keymap.Lookup(mode, keys)
+	if name == "" && partial {
+		// Wait for the rest of the sequence
+		return nil
+	}
+
+	pending := a.pending
+	a.pending = nil
+	if mode == CommandMode {
+		// Command mode lasts for a single binding
+		a.mode = PassthroughMode
+	}
+
+	if name != "" {
+		act, _ := lookupAction(name)
+		return act.run(a)
+	}
+	switch {
+	case mode == PassthroughMode:
+		// Not a binding, so the keys are typed into the active session
+		for _, k := range pending {
+			a.terminal.Update(k)
+		}
+	case len(pending) == 1 && msg.String() == prefix:
+		// Pressing the prefix twice sends it to the session
+		a.terminal.Update(msg)
+	}
+	// Any other key in command mode, such as Esc, leaves it without effect
+	return nil
+}
+
+// handlePromptKey edits the open prompt and runs it when submitted
+func (a *App) handlePromptKey(msg tea.KeyMsg) tea.Cmd {
+	p := a.prompt
+	done, submitted := p.update(msg)
+	if !done {
+		return nil
+	}
+	a.prompt = nil
+	if submitted {
+		return p.submit(a, string(p.value))
+	}
+	return nil
+}
+
+// openSearch starts a search in the active session
+func (a *App) openSearch() tea.Cmd {
+	a.search = newSearch(a.terminal.GetSession())
+	a.terminal.search = a.search
+	return nil
+}
+
+// handleSearchKey edits the search query and moves between matches.
+// Accepting the search leaves the view at the selected match, cancelling
+// it returns to the live screen.
+func (a *App) handleSearchKey(msg tea.KeyMsg) {
+	if a.search.session != a.terminal.GetSession() {
+		// The tab was switched with the mouse
+		a.search, a.terminal.search = nil, nil
+		return
+	}
+	done, cancelled := a.search.update(msg)
+	if !done {
+		return
+	}
+	if cancelled {
+		a.search.session.SetScrollOffset(0)
+	}
+	a.search, a.terminal.search = nil, nil
+}
+
+// handleGlobalSearchKey edits the query of the search across sessions. A
+// picked output match opens in its tab as a search with the same query,
+// with the match selected.
+func (a *App) handleGlobalSearchKey(msg tea.KeyMsg) tea.Cmd {
+	g := a.globalSearch
+	done, picked, cmd := g.update(msg, a.multiplexer)
+	if !done {
+		return cmd
+	}
+	a.globalSearch = nil
+	if picked == nil {
+		return nil
+	}
+	a.tabBar.SelectSession(picked.SessionID)
+	a.showActiveSession()
+	if picked.Title {
+		return nil
+	}
+	a.openSearch()
+	a.search.mode = g.mode
+	a.search.input.value = slices.Clone(g.input.value)
+	a.search.run()
+	a.search.selectMatch(picked.Match)
+	return nil
+}
+
+// openCopyMode starts copy mode in the active session
+func (a *App) openCopyMode() tea.Cmd {
+	a.copy = newCopyMode(a.terminal.GetSession(), a.config.CopyModeKeys == "emacs")
+	a.terminal.copy = a.copy
+	return nil
+}
+
+// handleCopyKey moves the copy mode cursor and selection. Leaving copy mode
+// returns to the live screen, and copied text goes to the clipboard of the
+// host terminal.
+func (a *App) handleCopyKey(msg tea.KeyMsg) {
+	if a.copy.session != a.terminal.GetSession() {
+		// The tab was switched with the mouse
+		a.copy, a.terminal.copy = nil, nil
+		return
+	}
+	done, text := a.copy.update(msg)
+	if !done {
+		return
+	}
+	a.copy.session.SetScrollOffset(0)
+	a.copy, a.terminal.copy = nil, nil
+	if text == "" {
+		return
+	}
+	if err := a.copyToClipboard(text); err != nil {
+		a.notice = "copy: " + err.Error()
+		return
+	}
+	a.notice = fmt.Sprintf("copied %d characters", utf8.RuneCountInString(text))
+}
+
+// SetHostOutput sets the terminal the app is shown on, for sequences that
+// Bubble Tea has no command for
+func (a *App) SetHostOutput(w io.Writer) {
+	a.hostOutput = w
+}
+
+// copyToClipboard sets the clipboard of the host terminal with OSC 52,
+// which reaches it over SSH too
+func (a *App) copyToClipboard(text string) error {
+	if a.hostOutput == nil {
+		return errors.New("no terminal to copy to")
+	}
+	_, err := osc52.New(text).WriteTo(a.hostOutput)
+	return err
+}
+
+// handleProfileMenuKey moves through the profile menu and opens a tab with
+// the picked profile
+func (a *App) handleProfileMenuKey(msg tea.KeyMsg) tea.Cmd {
+	done, picked := a.profileMenu.update(msg)
+	if !done {
+		return nil
+	}
+	a.profileMenu = nil
+	if picked != nil {
+		return a.createSession(picked.Options())
+	}
+	return nil
+}
+
+// openProfileMenu shows the menu of profiles for a new tab
+func (a *App) openProfileMenu() tea.Cmd {
+	switch {
+	case a.profilesErr != nil:
+		a.notice = "profiles are invalid, see settings"
+	case len(a.config.Profiles) == 0:
+		a.notice = "no profiles configured"
+	default:
+		a.profileMenu = newProfileMenu(a.config)
+	}
+	return nil
+}
+
+// reloadConfig reads the config file again and applies it in every
+// client. A file that cannot be parsed or has invalid bindings leaves the
+// current config in place. Scrollback settings apply to open sessions, the
+// rest to sessions opened from then on.
+func (a *App) reloadConfig() tea.Cmd {
+	config, err := data.LoadConfig()
+	if err != nil {
+		// Keep the current config rather than falling back to the defaults
+		a.notice = "reload config: " + err.Error()
+		return nil
+	}
+	if _, err := NewKeymap(config); err != nil {
+		a.keymapErr = err
+		a.settingsMode = true
+		return nil
+	}
+	// Published to the other clients through the multiplexer
+	a.multiplexer.SetConfig(config)
+	a.applyConfig(config)
+	a.settingsMode = a.profilesErr != nil
+	a.notice = "config reloaded"
+	return nil
+}
+
+// showActiveSession points the terminal at the active session
+func (a *App) showActiveSession() {
+	session, err := a.multiplexer.GetSession(a.tabBar.GetActiveSessionID())
+	if err != nil {
+		a.terminal.SetSession(nil)
+		return
+	}
+	a.terminal.SetSession(session)
+}
+
 // View renders the entire app
 func (a *App) View() string {
 	if a.helpMode {
@@ -122,89 +444,191 @@ func (a *App) View() string {
 		return a.renderSettings()
 	}
 
-	tabView := a.tabBar.View()
+	if a.profileMenu != nil {
+		return a.profileMenu.view(a.width, a.height, a.theme)
+	}
+
+	if a.globalSearch != nil {
+		return a.globalSearch.view(a.width, a.height, a.theme, a.multiplexer)
+	}
+
+	tabView := lipgloss.JoinHorizontal(
+		lipgloss.Top,
+		a.tabBar.View(),
+		renderModeIndicator(a.mode, a.theme),
+	)
 	terminalView := a.terminal.View()
 
+	switch {
+	case a.prompt != nil:
+		return lipgloss.JoinVertical(
+			lipgloss.Left,
+			tabView,
+			a.prompt.view(a.width, a.theme),
+			terminalView,
+		)
+	case a.search != nil:
+		return lipgloss.JoinVertical(
+			lipgloss.Left,
+			tabView,
+			a.search.view(a.width, a.theme),
+			terminalView,
+		)
+	case a.copy != nil:
+		return lipgloss.JoinVertical(
+			lipgloss.Left,
+			tabView,
+			a.copy.view(a.width, a.theme),
+			terminalView,
+		)
+	case a.notice != "":
+		return lipgloss.JoinVertical(
+			lipgloss.Left,
+			tabView,
+			renderNotice(a.notice, a.width, a.theme),
+			terminalView,
+		)
+	}
+
+	// The tab this client shows; other clients may be on other tabs
+	cwd := a.terminal.GetSession().Cwd()
 	content := lipgloss.JoinVertical(
 		lipgloss.Left,
 		tabView,
-		lipgloss.NewStyle().
-			BorderStyle(lipgloss.NormalBorder()).
-			BorderTop(true).
-			Render(terminalView),
+		renderStatus(cwd, a.width, a.theme),
+		terminalView,
 	)
 
 	return content
 }
 
+// renderStatus renders the rule under the tab bar, showing the working
+// directory of the current tab
+func renderStatus(cwd string, width int, theme *Theme) string {
+	if cwd == "" || width < 8 {
+		return strings.Repeat("─", max(width, 0))
+	}
+	if home, err := os.UserHomeDir(); err == nil {
+		if cwd == home {
+			cwd = "~"
+		} else if rest, ok := strings.CutPrefix(cwd, home+"/"); ok {
+			cwd = "~/" + rest
+		}
+	}
+	// Keep the end of long paths, the directory itself
+	if room := width - 4; runewidth.StringWidth(cwd) > room {
+		cwd = runewidth.TruncateLeft(cwd, runewidth.StringWidth(cwd)-room+1, "…")
+	}
+	label := theme.NewStyle().
+		Foreground(lipgloss.Color(theme.TabInactiveFg)).
+		Render(" " + cwd + " ")
+	return "─" + label + strings.Repeat("─", max(width-1-lipgloss.Width(label), 0))
+}
+
+// renderNotice renders a message on the line under the tab bar
+func renderNotice(notice string, width int, theme *Theme) string {
+	text := truncateStr(strings.ReplaceAll(notice, "\n", "; "), max(width-2, 4))
+	return theme.NewStyle().
+		Width(width).
+		Foreground(lipgloss.Color(theme.TabFocusedFg)).
+		Render(" " + text)
+}
+
+// layout sizes the components and gives every session's PTY the size of
+// the terminal pane. Call it whenever the window or the layout changes.
+func (a *App) layout() {
+	if a.tabBar != nil {
+		a.tabBar.SetSize(a.width-modeIndicatorWidth, 1)
+	}
+	if a.terminal != nil {
+		// Tab bar and the border below it take 2 rows
+		a.terminal.SetSize(a.width, a.height-2)
+		a.multiplexer.Resize(a.terminal.ScreenSize())
+	}
+}
+
 // createNewSession creates a new terminal session
 func (a *App) createNewSession() tea.Cmd {
-	sessionID := fmt.Sprintf("session-%d", a.sessionID)
-	a.sessionID++
+	if a.profilesErr == nil && a.config.DefaultProfile != "" {
+		if profile, ok := a.config.Profile(a.config.DefaultProfile); ok {
+			return a.createSession(profile.Options())
+		}
+	}
+	return a.createSession(a.config.NewTab)
+}
 
-	session, err := a.multiplexer.CreateSession(sessionID)
-	if err != nil {
+// createSession opens a tab with the given launch options
+func (a *App) createSession(opts data.SessionOptions) tea.Cmd {
+	if opts.Dir == "" && a.config.InheritCwd {
+		opts.Dir = a.terminal.GetSession().Cwd()
+	}
+	if _, err := a.multiplexer.NewSession(opts); err != nil {
+		a.notice = "new tab: " + err.Error()
 		return nil
 	}
-
-	session.SetName(fmt.Sprintf("shell-%d", a.sessionID-1))
 	a.tabBar.UpdateSessions()
 
 	return nil
 }
 
-// closeCurrentSession closes the current session
+// closeCurrentSession closes the current session. Its processes get a
+// grace period to exit, so the tab goes away before they have ended.
 func (a *App) closeCurrentSession() tea.Cmd {
 	activeID := a.tabBar.GetActiveSessionID()
-	if activeID != "" {
-		a.multiplexer.CloseSession(activeID)
-		a.tabBar.UpdateSessions()
+	if activeID == "" {
+		return nil
+	}
+	return func() tea.Msg {
+		return TabClosedMsg{SessionID: activeID, Err: a.multiplexer.CloseSession(activeID)}
+	}
+}
+
+// restartCurrentSession runs the command of the current tab again if it
+// has exited. Starting the process blocks, so it runs as a command.
+func (a *App) restartCurrentSession() tea.Cmd {
+	activeID := a.tabBar.GetActiveSessionID()
+	if activeID == "" {
+		return nil
+	}
+	return func() tea.Msg {
+		return TabRestartedMsg{SessionID: activeID, Err: a.multiplexer.RestartSession(activeID)}
 	}
-	return nil
 }
 
 // renderHelp renders the help screen
 func (a *App) renderHelp() string {
-	helpText := `
+	helpText := fmt.Sprintf(`
 ╔════════════════════════════════════════════════════════════════╗
 ║                    TERBOX - HELP                               ║
 ╚════════════════════════════════════════════════════════════════╝
 
-KEYBOARD SHORTCUTS:
-  Ctrl+T            Create new terminal tab
-  Ctrl+W            Close current tab
-  Ctrl+S            Open settings
-  Ctrl+H            Show this help
-  Ctrl+Right        Switch to next tab
-  Ctrl+Left         Switch to previous tab
-  1-9               Jump to specific tab (1=first, 9=ninth)
-  Ctrl+Q            Quit application
-
+%s
 FEATURES:
   • Web browser-like tab interface
   • One tab per terminal session
-  • Auto-renaming tabs based on latest command
+  • Tabs named after the running command or the title it sets
   • Configurable shell (default: /bin/sh)
   • Multiple themes available
   • Cross-platform (Linux and macOS)
 
 TAB MANAGEMENT:
   • Each tab represents an independent terminal session
-  • Tabs automatically rename to show the last command
+  • Tabs show the command in the foreground, or else the title the
+    program set, falling back to the session name
   • Click on tabs to switch (mouse support)
   • Close tabs without affecting others
 
 SETTINGS:
-  Press Ctrl+S to open settings and:
+  Open settings to:
   • Change default shell
   • Select theme
   • Configure keybindings
   • View advanced options
 
 Press any key to return to terminal...
-`
+`, a.renderConfigErrors()+a.renderKeyBindings(true))
 
-	box := lipgloss.NewStyle().
+	box := a.theme.NewStyle().
 		BorderStyle(lipgloss.RoundedBorder()).
 		BorderForeground(PrimaryColor).
 		Padding(2, 4).
@@ -240,18 +664,14 @@ AVAILABLE THEMES:
   • dracula       - Dracula theme
   • nord          - Nord theme
 
-KEYBINDINGS:
-  New Tab       (Ctrl+T)       Switch Previous  (Ctrl+Left)
-  Close Tab     (Ctrl+W)       Jump to Tab      (1-9)
-  Settings      (Ctrl+S)       Quit             (Ctrl+Q)
-  Help          (Ctrl+H)       Switch Next      (Ctrl+Right)
-
+%s
 To change settings, edit ~/.config/terbox/config.json
+and reload it with the reload_config binding.
 
-Press Ctrl+S to close settings...
-`, a.config.Shell, a.config.Theme, a.multiplexer.SessionCount(), now)
+Press any key to return to terminal...
+`, a.config.Shell, a.config.Theme, a.multiplexer.SessionCount(), now, a.renderConfigErrors()+a.renderKeyBindings(false))
 
-	box := lipgloss.NewStyle().
+	box := a.theme.NewStyle().
 		BorderStyle(lipgloss.RoundedBorder()).
 		BorderForeground(PrimaryColor).
 		Padding(2, 4).
@@ -266,3 +686,57 @@ Press Ctrl+S to close settings...
 		box,
 	)
 }
+
+// renderConfigErrors explains why parts of the config were rejected
+func (a *App) renderConfigErrors() string {
+	var sb strings.Builder
+	writeErrors := func(heading string, err error) {
+		if err == nil {
+			return
+		}
+		sb.WriteString(heading + "\n")
+		for _, line := range strings.Split(err.Error(), "\n") {
+			sb.WriteString("  " + line + "\n")
+		}
+		sb.WriteString("\n")
+	}
+	writeErrors("KEYBINDING ERRORS (using the default keybindings):", a.keymapErr)
+	writeErrors("PROFILE ERRORS (profiles disabled):", a.profilesErr)
+	return sb.String()
+}
+
+// renderKeyBindings lists the bindings of the key tables, generated from
+// the keymap so it always matches what the keys do. The command mode table
+// is included when withCommands is set.
+func (a *App) renderKeyBindings(withCommands bool) string {
+	var sb strings.Builder
+	sb.WriteString("KEYBOARD SHORTCUTS:\n")
+	a.writeKeyTable(&sb, PassthroughMode)
+
+	if prefix := a.keymap.Prefix(); prefix != "" && withCommands {
+		fmt.Fprintf(&sb, "\nCOMMAND MODE (press %s, then a key):\n", formatChord(prefix))
+		a.writeKeyTable(&sb, CommandMode)
+		fmt.Fprintf(&sb, "  %-18sSend %s to the shell\n", formatChord(prefix), formatChord(prefix))
+		fmt.Fprintf(&sb, "  %-18sCancel\n", "Any other key")
+	}
+	return sb.String()
+}
+
+// writeKeyTable writes one line per bound action of a key table. Tab
+// selection bound to single keys is folded into one line.
+func (a *App) writeKeyTable(sb *strings.Builder, mode InputMode) {
+	var tabKeys []string
+	for _, act := range actions {
+		keys := a.keymap.Keys(mode, act.name)
+		if strings.HasPrefix(act.name, "select_tab_") && len(keys) == 1 {
+			tabKeys = append(tabKeys, keys[0])
+			continue
+		}
+		if len(keys) > 0 {
+			fmt.Fprintf(sb, "  %-18s%s\n", strings.Join(keys, " / "), act.description)
+		}
+	}
+	if len(tabKeys) > 0 {
+		fmt.Fprintf(sb, "  %-18s%s\n", strings.Join(tabKeys, " "), "Jump to tab")
+	}
+}
@@ -0,0 +1,480 @@
+package ui
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+	"terbox/internal/data"
+	"terbox/internal/vt"
+	"unicode"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+)
+
+// selectKind is what a copy mode selection covers
+type selectKind int
+
+const (
+	selectNone  selectKind = iota
+	selectChar             // from one cell to another, following the text
+	selectLine             // whole lines
+	selectBlock            // a rectangle of columns
+)
+
+// String returns the name shown in the copy mode status
+func (k selectKind) String() string {
+	switch k {
+	case selectChar:
+		return "char"
+	case selectLine:
+		return "line"
+	case selectBlock:
+		return "block"
+	default:
+		return ""
+	}
+}
+
+// copyMode moves a cursor over the screen and scrollback of a session with
+// vi or emacs keys, and selects text to copy
+type copyMode struct {
+	session *data.TerminalSession
+	emacs   bool
+	cursor  vt.Pos
+	anchor  vt.Pos // where the selection started
+	kind    selectKind
+
+	// Refreshed on every key
+	width, height int
+	first, end    uint64 // lines that can be visited, as in vt.Screen.LineRange
+	cached        uint64
+	cache         vt.Line
+	cacheOK       bool
+}
+
+// newCopyMode starts copy mode in a session at its cursor, or at the bottom
+// of the view when it is scrolled back
+func newCopyMode(session *data.TerminalSession, emacs bool) *copyMode {
+	c := &copyMode{session: session, emacs: emacs}
+	c.refresh()
+	x, y, _ := session.Screen.Cursor()
+	if offset := session.ScrollOffset(); offset > 0 {
+		x, y = 0, c.height-1
+	}
+	c.cursor = vt.Pos{Line: c.top() + uint64(max(y, 0)), Col: x}
+	c.clamp()
+	return c
+}
+
+// refresh reads the size and line range of the screen, which output and
+// resizing change
+func (c *copyMode) refresh() {
+	screen := c.session.Screen
+	c.width, c.height = screen.Size()
+	c.first, c.end = screen.LineRange()
+	c.cacheOK = false
+}
+
+// top returns the number of the first line in view. The alternate screen
+// is never scrolled back.
+func (c *copyMode) top() uint64 {
+	top := c.end - uint64(c.height) - uint64(c.session.ScrollOffset())
+	if top < c.first {
+		return c.first
+	}
+	return top
+}
+
+// line returns a line by number, keeping the last one read
+func (c *copyMode) line(n uint64) vt.Line {
+	if !c.cacheOK || c.cached != n {
+		c.cache, _ = c.session.Screen.NumberedLine(n)
+		c.cached, c.cacheOK = n, true
+	}
+	return c.cache
+}
+
+// update handles a key. It reports whether copy mode is finished, and the
+// text to copy if some was copied.
+func (c *copyMode) update(msg tea.KeyMsg) (done bool, text string) {
+	c.refresh()
+	c.clamp()
+	bindings := viCopyKeys
+	if c.emacs {
+		bindings = emacsCopyKeys
+	}
+
+	half := max(c.height/2, 1)
+	switch copyCommand(bindings, msg.String()) {
+	case "left":
+		c.left()
+	case "right":
+		c.right()
+	case "up":
+		c.down(-1)
+	case "down":
+		c.down(1)
+	case "half-page-up":
+		c.down(-half)
+	case "half-page-down":
+		c.down(half)
+	case "page-up":
+		c.down(-c.height)
+	case "page-down":
+		c.down(c.height)
+	case "word-start":
+		c.nextWord()
+	case "word-end":
+		c.wordEnd()
+	case "word-back":
+		c.prevWord()
+	case "line-start":
+		c.cursor.Col = 0
+	case "text-start":
+		c.cursor.Col = 0
+		if l := c.line(c.cursor.Line); l.Len() > 0 {
+			for c.cursor.Col < l.Len()-1 && c.class(c.cursor) == 0 {
+				c.cursor.Col++
+			}
+		}
+	case "line-end":
+		c.cursor.Col = max(c.line(c.cursor.Line).Len()-1, 0)
+	case "history-top":
+		c.cursor = vt.Pos{Line: c.first}
+	case "history-bottom":
+		c.cursor = vt.Pos{Line: c.end - 1}
+	case "view-top":
+		c.cursor.Line = c.top()
+	case "view-middle":
+		c.cursor.Line = c.top() + uint64(c.height/2)
+	case "view-bottom":
+		c.cursor.Line = c.top() + uint64(c.height-1)
+	case "select-char":
+		c.toggle(selectChar)
+	case "select-line":
+		c.toggle(selectLine)
+	case "select-block":
+		c.toggle(selectBlock)
+	case "swap":
+		if c.kind != selectNone {
+			c.cursor, c.anchor = c.anchor, c.cursor
+		}
+	case "copy":
+		return true, c.text()
+	case "clear":
+		if c.kind != selectNone {
+			c.kind = selectNone
+			return false, ""
+		}
+		return true, ""
+	case "quit":
+		return true, ""
+	}
+	c.clamp()
+	c.scroll()
+	return false, ""
+}
+
+// Copy mode commands and the keys running them in vi and emacs style
+var (
+	viCopyKeys = map[string]string{
+		"left":           "h,left,backspace",
+		"right":          "l,right, ",
+		"up":             "k,up,ctrl+p",
+		"down":           "j,down,ctrl+n",
+		"half-page-up":   "ctrl+u",
+		"half-page-down": "ctrl+d",
+		"page-up":        "ctrl+b,pgup",
+		"page-down":      "ctrl+f,pgdown",
+		"word-start":     "w",
+		"word-end":       "e",
+		"word-back":      "b",
+		"line-start":     "0,home",
+		"text-start":     "^",
+		"line-end":       "$,end",
+		"history-top":    "g",
+		"history-bottom": "G",
+		"view-top":       "H",
+		"view-middle":    "M",
+		"view-bottom":    "L",
+		"select-char":    "v",
+		"select-line":    "V",
+		"select-block":   "ctrl+v",
+		"swap":           "o",
+		"copy":           "y,enter",
+		"clear":          "esc",
+		"quit":           "q,ctrl+c",
+	}
+	emacsCopyKeys = map[string]string{
+		"left":           "ctrl+b,left",
+		"right":          "ctrl+f,right",
+		"up":             "ctrl+p,up",
+		"down":           "ctrl+n,down",
+		"page-up":        "alt+v,pgup",
+		"page-down":      "ctrl+v,pgdown",
+		"word-end":       "alt+f",
+		"word-back":      "alt+b",
+		"line-start":     "ctrl+a,home",
+		"text-start":     "alt+m",
+		"line-end":       "ctrl+e,end",
+		"history-top":    "alt+<",
+		"history-bottom": "alt+>",
+		"view-middle":    "alt+r",
+		"select-char":    "ctrl+@",
+		"select-line":    "L",
+		"select-block":   "R",
+		"swap":           "ctrl+x",
+		"copy":           "alt+w,enter",
+		"clear":          "esc",
+		"quit":           "ctrl+g,q,ctrl+c",
+	}
+)
+
+// copyCommand returns the command a key runs, or "" if none
+func copyCommand(bindings map[string]string, key string) string {
+	for command, keys := range bindings {
+		if slices.Contains(strings.Split(keys, ","), key) {
+			return command
+		}
+	}
+	return ""
+}
+
+// toggle starts a selection of a kind at the cursor, switches the current
+// selection to it, or ends the selection if it is already of that kind
+func (c *copyMode) toggle(kind selectKind) {
+	switch c.kind {
+	case kind:
+		c.kind = selectNone
+	case selectNone:
+		c.kind, c.anchor = kind, c.cursor
+	default:
+		c.kind = kind
+	}
+}
+
+// left moves the cursor one character left
+func (c *copyMode) left() {
+	if c.cursor.Col > 0 {
+		c.cursor.Col--
+	}
+}
+
+// right moves the cursor one character right, over both halves of a wide
+// character
+func (c *copyMode) right() {
+	l := c.line(c.cursor.Line)
+	step := 1
+	if c.cursor.Col < len(l.Cells) && l.Cells[c.cursor.Col].Width == 2 {
+		step = 2
+	}
+	c.cursor.Col = min(c.cursor.Col+step, c.width-1)
+}
+
+// down moves the cursor n lines down, or up if n is negative
+func (c *copyMode) down(n int) {
+	if n < 0 {
+		c.cursor.Line -= min(uint64(-n), c.cursor.Line-c.first)
+	} else {
+		c.cursor.Line += uint64(n)
+	}
+}
+
+// clamp keeps the cursor on a line that is kept, inside the screen width
+// and on the left half of a wide character
+func (c *copyMode) clamp() {
+	if c.cursor.Line < c.first {
+		c.cursor.Line = c.first
+	}
+	c.cursor.Line = min(c.cursor.Line, c.end-1)
+	c.cursor.Col = min(max(c.cursor.Col, 0), max(c.width-1, 0))
+	l := c.line(c.cursor.Line)
+	if c.cursor.Col > 0 && c.cursor.Col < len(l.Cells) && l.Cells[c.cursor.Col].Width == 0 {
+		c.cursor.Col--
+	}
+}
+
+// scroll scrolls the view as little as needed to show the cursor
+func (c *copyMode) scroll() {
+	offset := c.session.ScrollOffset()
+	top := c.top()
+	switch {
+	case c.cursor.Line < top:
+		offset += int(top - c.cursor.Line)
+	case c.cursor.Line >= top+uint64(c.height):
+		offset -= int(c.cursor.Line - top - uint64(c.height) + 1)
+	default:
+		return
+	}
+	c.session.SetScrollOffset(offset)
+}
+
+// class returns the kind of character at a position for word movement: 0
+// for blanks, 1 for letters, digits and underscores, 2 for anything else
+func (c *copyMode) class(p vt.Pos) int {
+	l := c.line(p.Line)
+	if p.Col >= len(l.Cells) {
+		return 0
+	}
+	cell := l.Cells[p.Col]
+	if cell.Width == 0 && p.Col > 0 {
+		cell = l.Cells[p.Col-1]
+	}
+	switch r := cell.Rune; {
+	case r == 0 || unicode.IsSpace(r):
+		return 0
+	case r == '_' || unicode.IsLetter(r) || unicode.IsDigit(r):
+		return 1
+	default:
+		return 2
+	}
+}
+
+// next returns the position after p, reading on into the next line, and
+// false if p is the last one
+func (c *copyMode) next(p vt.Pos) (vt.Pos, bool) {
+	if p.Col+1 < c.width {
+		return vt.Pos{Line: p.Line, Col: p.Col + 1}, true
+	}
+	if p.Line+1 < c.end {
+		return vt.Pos{Line: p.Line + 1}, true
+	}
+	return p, false
+}
+
+// prev returns the position before p and false if p is the first one
+func (c *copyMode) prev(p vt.Pos) (vt.Pos, bool) {
+	if p.Col > 0 {
+		return vt.Pos{Line: p.Line, Col: p.Col - 1}, true
+	}
+	if p.Line > c.first {
+		return vt.Pos{Line: p.Line - 1, Col: max(c.width-1, 0)}, true
+	}
+	return p, false
+}
+
+// nextWord moves the cursor to the start of the next word
+func (c *copyMode) nextWord() {
+	p, ok := c.cursor, true
+	for class := c.class(p); ok && class != 0 && c.class(p) == class; {
+		p, ok = c.next(p)
+	}
+	for ok && c.class(p) == 0 {
+		p, ok = c.next(p)
+	}
+	if ok {
+		c.cursor = p
+	}
+}
+
+// wordEnd moves the cursor to the end of the word, or of the next one if
+// it is already there
+func (c *copyMode) wordEnd() {
+	p, ok := c.next(c.cursor)
+	for ok && c.class(p) == 0 {
+		p, ok = c.next(p)
+	}
+	if !ok {
+		return
+	}
+	for class := c.class(p); ; {
+		q, ok := c.next(p)
+		if !ok || c.class(q) != class {
+			break
+		}
+		p = q
+	}
+	c.cursor = p
+}
+
+// prevWord moves the cursor to the start of the word, or of the previous
+// one if it is already there
+func (c *copyMode) prevWord() {
+	p, ok := c.prev(c.cursor)
+	for ok && c.class(p) == 0 {
+		p, ok = c.prev(p)
+	}
+	if !ok {
+		return
+	}
+	for class := c.class(p); ; {
+		q, ok := c.prev(p)
+		if !ok || c.class(q) != class {
+			break
+		}
+		p = q
+	}
+	c.cursor = p
+}
+
+// span returns the columns [lo, hi] of a line that are selected, and false
+// if none are
+func (c *copyMode) span(line uint64) (lo, hi int, ok bool) {
+	from, to := c.anchor, c.cursor
+	if to.Line < from.Line || to.Line == from.Line && to.Col < from.Col {
+		from, to = to, from
+	}
+	if c.kind == selectNone || line < from.Line || line > to.Line {
+		return 0, 0, false
+	}
+	lo, hi = 0, c.width-1
+	switch c.kind {
+	case selectChar:
+		if line == from.Line {
+			lo = from.Col
+		}
+		if line == to.Line {
+			hi = to.Col
+		}
+	case selectBlock:
+		lo, hi = min(from.Col, to.Col), max(from.Col, to.Col)
+	}
+	return lo, hi, true
+}
+
+// text returns the selected text, or "" if nothing is selected
+func (c *copyMode) text() string {
+	screen := c.session.Screen
+	switch c.kind {
+	case selectChar:
+		return screen.Text(c.anchor, c.cursor, false)
+	case selectLine:
+		from, to := c.anchor.Line, c.cursor.Line
+		if to < from {
+			from, to = to, from
+		}
+		return screen.Text(vt.Pos{Line: from}, vt.Pos{Line: to, Col: c.width - 1}, false)
+	case selectBlock:
+		return screen.Text(c.anchor, c.cursor, true)
+	default:
+		return ""
+	}
+}
+
+// view renders the copy mode status: the key style, the selection and the
+// cursor position
+func (c *copyMode) view(width int, theme *Theme) string {
+	style, hint := "vi", "v/V/ctrl+v: select  y: copy  q: quit"
+	if c.emacs {
+		style, hint = "emacs", "ctrl+space: select  alt+w: copy  ctrl+g: quit"
+	}
+	label := theme.NewStyle().
+		Bold(true).
+		Foreground(lipgloss.Color(theme.TabFocusedFg)).
+		Render(fmt.Sprintf(" Copy mode (%s): ", style))
+
+	status := fmt.Sprintf("line %d/%d", c.cursor.Line-c.first+1, c.end-c.first)
+	if c.kind != selectNone {
+		status = c.kind.String() + " selection, " + status
+	}
+	// The key hint goes first when the line is too narrow
+	room := max(width-lipgloss.Width(label), 0)
+	if lipgloss.Width(status+"  "+hint) <= room {
+		status += "  " + hint
+	}
+	status = theme.NewStyle().
+		Foreground(lipgloss.Color(theme.TabInactiveFg)).
+		Render(truncateStr(status, room))
+	return theme.NewStyle().Width(width).MaxWidth(width).Render(label + status)
+}
@@ -0,0 +1,158 @@
+package ui
+
+import (
+	"fmt"
+	"strings"
+	"terbox/internal/mux"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+)
+
+// globalSearchLimit is the number of output matches listed per session
+const globalSearchLimit = 200
+
+// globalSearchDelay is how long typing must pause before every session is
+// searched. Searching reads all the scrollback, decompressing any spilled
+// to a file, which is too slow to do on each key.
+const globalSearchDelay = 150 * time.Millisecond
+
+// globalSearch finds a query in the titles and output of every session
+// and lists the matches to jump to
+type globalSearch struct {
+	input   prompt
+	mode    searchMode
+	results []mux.SearchResult
+	tabs    int // number of sessions with results
+	cursor  int
+	err     error // why the query is not a valid regex
+	query   int   // number of the latest change to the query
+	pending bool  // whether the results are not for the latest query yet
+}
+
+// globalSearchMsg is sent when a change to the query of a search across
+// sessions has been left alone for globalSearchDelay
+type globalSearchMsg struct {
+	search *globalSearch
+	query  int
+}
+
+// update handles a key. It reports whether the search is finished, and
+// the picked result if one was picked. A changed query is searched for
+// once typing pauses, through the returned command.
+func (g *globalSearch) update(msg tea.KeyMsg, m *mux.Multiplexer) (done bool, picked *mux.SearchResult, cmd tea.Cmd) {
+	switch msg.Type {
+	case tea.KeyEnter:
+		if g.pending {
+			// Pick from the results of the query as typed
+			g.run(m)
+		}
+		if len(g.results) == 0 {
+			return false, nil, nil
+		}
+		return true, &g.results[g.cursor], nil
+	case tea.KeyEsc, tea.KeyCtrlC:
+		return true, nil, nil
+	case tea.KeyUp, tea.KeyCtrlP:
+		g.cursor = max(g.cursor-1, 0)
+	case tea.KeyDown, tea.KeyCtrlN:
+		g.cursor = min(g.cursor+1, max(len(g.results)-1, 0))
+	case tea.KeyCtrlR:
+		g.mode = (g.mode + 1) % (searchRegex + 1)
+		cmd = g.schedule()
+	default:
+		before := string(g.input.value)
+		g.input.update(msg)
+		if string(g.input.value) != before {
+			cmd = g.schedule()
+		}
+	}
+	return false, nil, cmd
+}
+
+// schedule starts the delay after which the query is searched for. Only
+// the last of several quick changes leads to a search.
+func (g *globalSearch) schedule() tea.Cmd {
+	g.query++
+	g.pending = true
+	msg := globalSearchMsg{search: g, query: g.query}
+	return tea.Tick(globalSearchDelay, func(time.Time) tea.Msg { return msg })
+}
+
+// run searches every session for the query
+func (g *globalSearch) run(m *mux.Multiplexer) {
+	g.results, g.tabs, g.cursor, g.err, g.pending = nil, 0, 0, nil, false
+	if len(g.input.value) == 0 {
+		return
+	}
+	re, err := g.mode.compile(string(g.input.value))
+	if err != nil {
+		g.err = err
+		return
+	}
+	g.results = m.Search(re, globalSearchLimit)
+	for i, r := range g.results {
+		if i == 0 || r.SessionID != g.results[i-1].SessionID {
+			g.tabs++
+		}
+	}
+}
+
+// view renders the query and a page of results around the cursor, each
+// with its tab and a preview of the line
+func (g *globalSearch) view(width, height int, theme *Theme, m *mux.Multiplexer) string {
+	var status string
+	switch {
+	case g.err != nil:
+		status = "invalid regex"
+	case len(g.input.value) == 0:
+		status = "ctrl+r: mode"
+	case g.pending:
+		status = "searching..."
+	default:
+		status = fmt.Sprintf("%d matches in %d tabs", len(g.results), g.tabs)
+	}
+	status = theme.NewStyle().
+		Foreground(lipgloss.Color(theme.TabInactiveFg)).
+		Render(" " + status + " ")
+	g.input.label = fmt.Sprintf("Search all tabs (%s):", g.mode)
+	input := g.input.view(max(width-lipgloss.Width(status), 0), theme) + status
+
+	// Two rows above the results and two below
+	rows := max(height-4, 1)
+	start := min(max(g.cursor-rows/2, 0), max(len(g.results)-rows, 0))
+	end := min(start+rows, len(g.results))
+
+	// Tab numbers as shown in the tab bar
+	index := make(map[string]int)
+	for i, id := range m.ListSessions() {
+		index[id] = i + 1
+	}
+	labelStyle := theme.NewStyle().Foreground(lipgloss.Color(theme.TabFocusedFg))
+	selected := theme.NewStyle().Reverse(true)
+
+	lines := []string{input, strings.Repeat("─", max(width, 0))}
+	for i := start; i < end; i++ {
+		r := g.results[i]
+		name := r.SessionID
+		if session, err := m.GetSession(r.SessionID); err == nil {
+			name = session.GetName()
+		}
+		label := fmt.Sprintf("[%d] %s", index[r.SessionID], truncateStr(name, 16))
+		where := "title"
+		if !r.Title {
+			where = fmt.Sprintf("line %d", r.Match.Line+1)
+		}
+		label = fmt.Sprintf(" %-22s %-11s ", label, where)
+		preview := truncateStr(strings.TrimSpace(r.Text), max(width-lipgloss.Width(label), 0))
+		line := labelStyle.Render(label) + preview
+		if i == g.cursor {
+			line = selected.Render(label + preview)
+		}
+		lines = append(lines, line)
+	}
+	lines = append(lines, "", " ↑/↓: select  enter: jump to match  esc: cancel")
+	return theme.NewStyle().Width(width).MaxWidth(width).Height(height).MaxHeight(height).
+		Render(strings.Join(lines, "\n"))
+}
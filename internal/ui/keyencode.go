@@ -0,0 +1,179 @@
+package ui
+
+import (
+	"strconv"
+	"strings"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// Modifier bits as encoded in xterm's "CSI 1 ; m X" sequences (m = 1 + bits)
+const (
+	modShift = 1
+	modAlt   = 2
+	modCtrl  = 4
+)
+
+// cursorKey describes a key sent as CSI/SS3 final byte, optionally modified
+type cursorKey struct {
+	final byte
+	mods  int
+}
+
+// cursorKeys are the keys affected by application cursor mode (DECCKM)
+var cursorKeys = map[tea.KeyType]cursorKey{
+	tea.KeyUp:             {'A', 0},
+	tea.KeyDown:           {'B', 0},
+	tea.KeyRight:          {'C', 0},
+	tea.KeyLeft:           {'D', 0},
+	tea.KeyHome:           {'H', 0},
+	tea.KeyEnd:            {'F', 0},
+	tea.KeyShiftUp:        {'A', modShift},
+	tea.KeyShiftDown:      {'B', modShift},
+	tea.KeyShiftRight:     {'C', modShift},
+	tea.KeyShiftLeft:      {'D', modShift},
+	tea.KeyShiftHome:      {'H', modShift},
+	tea.KeyShiftEnd:       {'F', modShift},
+	tea.KeyCtrlUp:         {'A', modCtrl},
+	tea.KeyCtrlDown:       {'B', modCtrl},
+	tea.KeyCtrlRight:      {'C', modCtrl},
+	tea.KeyCtrlLeft:       {'D', modCtrl},
+	tea.KeyCtrlHome:       {'H', modCtrl},
+	tea.KeyCtrlEnd:        {'F', modCtrl},
+	tea.KeyCtrlShiftUp:    {'A', modCtrl | modShift},
+	tea.KeyCtrlShiftDown:  {'B', modCtrl | modShift},
+	tea.KeyCtrlShiftRight: {'C', modCtrl | modShift},
+	tea.KeyCtrlShiftLeft:  {'D', modCtrl | modShift},
+	tea.KeyCtrlShiftHome:  {'H', modCtrl | modShift},
+	tea.KeyCtrlShiftEnd:   {'F', modCtrl | modShift},
+	tea.KeyF1:             {'P', 0},
+	tea.KeyF2:             {'Q', 0},
+	tea.KeyF3:             {'R', 0},
+	tea.KeyF4:             {'S', 0},
+	tea.KeyF13:            {'P', modShift},
+	tea.KeyF14:            {'Q', modShift},
+	tea.KeyF15:            {'R', modShift},
+	tea.KeyF16:            {'S', modShift},
+}
+
+// tildeKey describes a key sent as "CSI n ~", optionally modified
+type tildeKey struct {
+	code int
+	mods int
+}
+
+// tildeKeys are the editing and function keys sent as "CSI n ~"
+var tildeKeys = map[tea.KeyType]tildeKey{
+	tea.KeyInsert:     {2, 0},
+	tea.KeyDelete:     {3, 0},
+	tea.KeyPgUp:       {5, 0},
+	tea.KeyPgDown:     {6, 0},
+	tea.KeyCtrlPgUp:   {5, modCtrl},
+	tea.KeyCtrlPgDown: {6, modCtrl},
+	tea.KeyF5:         {15, 0},
+	tea.KeyF6:         {17, 0},
+	tea.KeyF7:         {18, 0},
+	tea.KeyF8:         {19, 0},
+	tea.KeyF9:         {20, 0},
+	tea.KeyF10:        {21, 0},
+	tea.KeyF11:        {23, 0},
+	tea.KeyF12:        {24, 0},
+	tea.KeyF17:        {15, modShift},
+	tea.KeyF18:        {17, modShift},
+	tea.KeyF19:        {18, modShift},
+	tea.KeyF20:        {19, modShift},
+}
+
+// encodeKey returns the bytes an xterm sends to the program for a key.
+// appCursor selects the SS3 form of unmodified cursor keys (DECCKM) and
+// bracketedPaste wraps pasted text in CSI 200~ / CSI 201~. Keypad keys
+// cannot be told apart from the main keys once decoded, so application
+// keypad mode has no effect on the encoding.
+func encodeKey(msg tea.KeyMsg, appCursor, bracketedPaste bool) []byte {
+	var out []byte
+
+	switch msg.Type {
+	case tea.KeyRunes:
+		text := []byte(string(msg.Runes))
+		if msg.Paste {
+			if bracketedPaste {
+				out = append(out, "\x1b[200~"...)
+				out = append(out, text...)
+				return append(out, "\x1b[201~"...)
+			}
+			return text
+		}
+		if msg.Alt {
+			out = append(out, 0x1b)
+		}
+		return append(out, text...)
+
+	case tea.KeySpace:
+		if msg.Alt {
+			out = append(out, 0x1b)
+		}
+		return append(out, ' ')
+
+	case tea.KeyShiftTab:
+		return []byte("\x1b[Z")
+	}
+
+	// C0 controls, Enter, Tab, Backspace (DEL) and Escape
+	if msg.Type >= 0 && msg.Type <= 31 || msg.Type == 127 {
+		if msg.Alt {
+			out = append(out, 0x1b)
+		}
+		return append(out, byte(msg.Type))
+	}
+
+	mods := 0
+	if msg.Alt {
+		mods = modAlt
+	}
+
+	if k, ok := cursorKeys[msg.Type]; ok {
+		mods |= k.mods
+		isFunction := k.final >= 'P' && k.final <= 'S'
+		switch {
+		case mods != 0:
+			return []byte("\x1b[1;" + strconv.Itoa(mods+1) + string(k.final))
+		case isFunction || appCursor:
+			return []byte{0x1b, 'O', k.final}
+		default:
+			return []byte{0x1b, '[', k.final}
+		}
+	}
+
+	if k, ok := tildeKeys[msg.Type]; ok {
+		mods |= k.mods
+		seq := "\x1b[" + strconv.Itoa(k.code)
+		if mods != 0 {
+			seq += ";" + strconv.Itoa(mods+1)
+		}
+		return []byte(seq + "~")
+	}
+
+	return nil
+}
+
+// EncodeKeyName returns the bytes sent for a key named as in keybindings,
+// such as "enter", "ctrl+c" or "alt+up". ok is false when name is not a
+// key, so callers can send it as text instead.
+func EncodeKeyName(name string, appCursor bool) (input []byte, ok bool) {
+	chord, err := parseChord(name)
+	if err != nil {
+		return nil, false
+	}
+	key := tea.Key{}
+	if rest, found := strings.CutPrefix(chord, "alt+"); found && rest != "" {
+		key.Alt = true
+		chord = rest
+	}
+	if t, found := keyNames[chord]; found {
+		key.Type = t
+	} else {
+		key.Type = tea.KeyRunes
+		key.Runes = []rune(chord)
+	}
+	return encodeKey(tea.KeyMsg(key), appCursor, false), true
+}
@@ -0,0 +1,275 @@
+package ui
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"sort"
+	"strings"
+	"terbox/internal/data"
+	"unicode/utf8"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// keyNames maps every key name Bubble Tea reports, such as "ctrl+t" or
+// "pgup", to its key type
+var keyNames = func() map[string]tea.KeyType {
+	names := make(map[string]tea.KeyType)
+	for t := tea.KeyF20; t <= tea.KeyBackspace; t++ {
+		if name := t.String(); name != "" {
+			names[name] = t
+		}
+	}
+	return names
+}()
+
+// keyBinding binds a sequence of chords to an action
+type keyBinding struct {
+	keys   []string // Chords in tea.KeyMsg.String() form
+	action string
+}
+
+// Keymap maps key sequences to actions, with one key table per input mode
+type Keymap struct {
+	prefix string
+	tables map[InputMode][]keyBinding
+}
+
+// keyTables are the config fields holding each mode's bindings
+var keyTables = []struct {
+	mode  InputMode
+	field string
+	get   func(*data.Config) map[string]string
+}{
+	{PassthroughMode, "keybindings", func(c *data.Config) map[string]string { return c.KeyBindings }},
+	{CommandMode, "command_keybindings", func(c *data.Config) map[string]string { return c.CommandKeyBindings }},
+}
+
+// NewKeymap builds a keymap from the configured bindings. A binding is a
+// comma separated list of alternatives; each alternative is a space
+// separated key sequence such as "ctrl+x t". All problems are reported
+// together so they can be fixed in one go.
+func NewKeymap(config *data.Config) (*Keymap, error) {
+	km := &Keymap{tables: make(map[InputMode][]keyBinding)}
+	var errs []error
+
+	if config.Prefix != "" {
+		prefix, err := parseChord(config.Prefix)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("prefix: %w", err))
+		}
+		km.prefix = prefix
+	}
+
+	for _, table := range keyTables {
+		bindings := table.get(config)
+		names := make([]string, 0, len(bindings))
+		for name := range bindings {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+
+		for _, name := range names {
+			if _, ok := lookupAction(name); !ok {
+				errs = append(errs, fmt.Errorf("%s: unknown action %q", table.field, name))
+				continue
+			}
+			keys, err := parseBinding(bindings[name])
+			if err != nil {
+				errs = append(errs, fmt.Errorf("%s: action %q: %w", table.field, name, err))
+				continue
+			}
+			for _, seq := range keys {
+				km.tables[table.mode] = append(km.tables[table.mode], keyBinding{keys: seq, action: name})
+			}
+		}
+		errs = append(errs, km.conflicts(table.mode, table.field)...)
+	}
+
+	if err := errors.Join(errs...); err != nil {
+		return nil, err
+	}
+	return km, nil
+}
+
+// DefaultKeymap returns the keymap of the default configuration
+func DefaultKeymap() *Keymap {
+	km, err := NewKeymap(data.DefaultConfig())
+	if err != nil {
+		panic("invalid default keybindings: " + err.Error())
+	}
+	return km
+}
+
+// conflicts reports sequences bound twice, sequences that can never fire
+// because they extend another binding, and bindings hidden by the prefix
+func (km *Keymap) conflicts(mode InputMode, field string) []error {
+	var errs []error
+	bindings := km.tables[mode]
+	for i, a := range bindings {
+		if mode == PassthroughMode && km.prefix != "" && a.keys[0] == km.prefix {
+			errs = append(errs, fmt.Errorf("%s: action %q: %q starts with the prefix key", field, a.action, formatKeys(a.keys)))
+		}
+		for _, b := range bindings[i+1:] {
+			switch {
+			case slices.Equal(a.keys, b.keys):
+				errs = append(errs, fmt.Errorf("%s: %q is bound to both %q and %q", field, formatKeys(a.keys), a.action, b.action))
+			case isPrefix(a.keys, b.keys):
+				errs = append(errs, fmt.Errorf("%s: %q (%s) hides %q (%s)", field, formatKeys(a.keys), a.action, formatKeys(b.keys), b.action))
+			case isPrefix(b.keys, a.keys):
+				errs = append(errs, fmt.Errorf("%s: %q (%s) hides %q (%s)", field, formatKeys(b.keys), b.action, formatKeys(a.keys), a.action))
+			}
+		}
+	}
+	return errs
+}
+
+// Prefix returns the key entering command mode, or "" if there is none
+func (km *Keymap) Prefix() string {
+	return km.prefix
+}
+
+// Lookup resolves keys pressed in mode. It returns the bound action, or
+// partial set when the keys start a longer sequence.
+func (km *Keymap) Lookup(mode InputMode, keys []string) (action string, partial bool) {
+	for _, b := range km.tables[mode] {
+		if slices.Equal(b.keys, keys) {
+			return b.action, false
+		}
+		if isPrefix(keys, b.keys) {
+			partial = true
+		}
+	}
+	return "", partial
+}
+
+// Keys returns the display form of every sequence bound to action in mode
+func (km *Keymap) Keys(mode InputMode, action string) []string {
+	var keys []string
+	for _, b := range km.tables[mode] {
+		if b.action == action {
+			keys = append(keys, formatKeys(b.keys))
+		}
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// isPrefix reports whether a is a strict prefix of b
+func isPrefix(a, b []string) bool {
+	return len(a) < len(b) && slices.Equal(a, b[:len(a)])
+}
+
+// parseBinding parses a comma separated list of key sequences
+func parseBinding(s string) ([][]string, error) {
+	var seqs [][]string
+	for _, alt := range strings.Split(s, ",") {
+		fields := strings.Fields(alt)
+		if len(fields) == 0 {
+			continue
+		}
+		seq := make([]string, len(fields))
+		for i, field := range fields {
+			chord, err := parseChord(field)
+			if err != nil {
+				return nil, err
+			}
+			seq[i] = chord
+		}
+		seqs = append(seqs, seq)
+	}
+	return seqs, nil
+}
+
+// parseChord converts a chord such as "Shift+Ctrl+Up" into the form used by
+// tea.KeyMsg.String(), "ctrl+shift+up". "space" and "comma" name the keys
+// that cannot be written literally in a binding.
+func parseChord(s string) (string, error) {
+	var alt, ctrl, shift bool
+	rest := s
+	for {
+		i := strings.Index(rest, "+")
+		if i <= 0 || i == len(rest)-1 {
+			break
+		}
+		switch strings.ToLower(rest[:i]) {
+		case "alt":
+			alt = true
+		case "ctrl":
+			ctrl = true
+		case "shift":
+			shift = true
+		default:
+			return "", fmt.Errorf("unknown modifier %q in %q", rest[:i], s)
+		}
+		rest = rest[i+1:]
+	}
+
+	key := rest
+	if ctrl || shift || utf8.RuneCountInString(key) > 1 {
+		key = strings.ToLower(key)
+	}
+	switch key {
+	case "space":
+		key = " "
+	case "comma":
+		key = ","
+	}
+
+	name := key
+	if shift {
+		name = "shift+" + name
+	}
+	if ctrl {
+		name = "ctrl+" + name
+	}
+	isRune := !ctrl && !shift && utf8.RuneCountInString(key) == 1
+	if _, ok := keyNames[name]; !isRune && !ok {
+		return "", fmt.Errorf("unknown key %q", s)
+	}
+	if alt {
+		name = "alt+" + name
+	}
+	return name, nil
+}
+
+// formatKeys renders a key sequence for display, e.g. "Ctrl+X T"
+func formatKeys(keys []string) string {
+	parts := make([]string, len(keys))
+	for i, chord := range keys {
+		parts[i] = formatChord(chord)
+	}
+	return strings.Join(parts, " ")
+}
+
+// formatChord renders a chord for display, e.g. "Ctrl+Right"
+func formatChord(chord string) string {
+	var sb strings.Builder
+	key := chord
+	ctrl := false
+	for {
+		i := strings.Index(key, "+")
+		if i <= 0 || i == len(key)-1 {
+			break
+		}
+		ctrl = ctrl || key[:i] == "ctrl"
+		sb.WriteString(capitalize(key[:i]) + "+")
+		key = key[i+1:]
+	}
+	switch {
+	case key == " ":
+		key = "Space"
+	case ctrl || utf8.RuneCountInString(key) > 1:
+		// Control chords ignore case, so show them as Ctrl+T
+		key = capitalize(key)
+	}
+	sb.WriteString(key)
+	return sb.String()
+}
+
+// capitalize upper-cases the first letter of s
+func capitalize(s string) string {
+	r, size := utf8.DecodeRuneInString(s)
+	return strings.ToUpper(string(r)) + s[size:]
+}
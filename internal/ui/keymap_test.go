@@ -0,0 +1,81 @@
+package ui_test
+
+import (
+	"strings"
+	"terbox/internal/data"
+	"terbox/internal/ui"
+	"testing"
+)
+
+// keymapCase is a configuration given to NewKeymap and what it must yield
+type keymapCase struct {
+	name     string
+	prefix   string
+	keys     map[string]string // keybindings
+	command  map[string]string // command_keybindings
+	err      string            // substring of the error; "" if none is expected
+	sequence []string          // for a valid keymap, keys that must resolve
+	action   string            // to this passthrough action
+}
+
+var keymapCases = []keymapCase{
+	{
+		name:    "unknown action",
+		command: map[string]string{"open_window": "w"},
+		err:     `command_keybindings: unknown action "open_window"`,
+	},
+	{
+		name:    "duplicate sequence",
+		command: map[string]string{"new_tab": "c", "close_tab": "c"},
+		err:     `command_keybindings: "c" is bound to both`,
+	},
+	{
+		name: "prefix hiding a longer sequence",
+		keys: map[string]string{"new_tab": "ctrl+x", "close_tab": "ctrl+x k"},
+		err:  `keybindings: "Ctrl+X" (new_tab) hides "Ctrl+X k" (close_tab)`,
+	},
+	{
+		name:   "binding starting with the prefix",
+		prefix: "ctrl+b",
+		keys:   map[string]string{"new_tab": "ctrl+b t"},
+		err:    `keybindings: action "new_tab": "Ctrl+B t" starts with the prefix key`,
+	},
+	{
+		name:     "chord normalisation",
+		prefix:   "ctrl+b",
+		keys:     map[string]string{"next_tab": "Shift+Ctrl+Up"},
+		sequence: []string{"ctrl+shift+up"},
+		action:   "next_tab",
+	},
+}
+
+func TestNewKeymap(t *testing.T) {
+	for _, tc := range keymapCases {
+		t.Run(tc.name, func(t *testing.T) {
+			config := data.DefaultConfig()
+			config.Prefix = tc.prefix
+			config.KeyBindings = tc.keys
+			config.CommandKeyBindings = tc.command
+
+			km, err := ui.NewKeymap(config)
+			if tc.err != "" {
+				if err == nil || !strings.Contains(err.Error(), tc.err) {
+					t.Fatalf("NewKeymap() error = %v, want one containing %q", err, tc.err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("NewKeymap() error = %v", err)
+			}
+			if action, _ := km.Lookup(ui.PassthroughMode, tc.sequence); action != tc.action {
+				t.Errorf("Lookup(%q) = %q, want %q", tc.sequence, action, tc.action)
+			}
+		})
+	}
+}
+
+func TestDefaultKeymap(t *testing.T) {
+	if _, err := ui.NewKeymap(data.DefaultConfig()); err != nil {
+		t.Errorf("default keybindings: %v", err)
+	}
+}
@@ -10,6 +10,7 @@ type List struct {
 	choices  []string
 	cursor   int
 	selected map[int]struct{}
+	menu     bool // Single choice without checkboxes, read with Cursor
 }
 
 func NewList(choices []string) List {
@@ -20,6 +21,19 @@ func NewList(choices []string) List {
 	}
 }
 
+// NewMenu creates a list for picking one choice. The caller handles enter
+// and reads the choice with Cursor.
+func NewMenu(choices []string) List {
+	l := NewList(choices)
+	l.menu = true
+	return l
+}
+
+// Cursor returns the index of the highlighted choice
+func (l List) Cursor() int {
+	return l.cursor
+}
+
 func (l List) Init() tea.Cmd {
 	return nil
 }
@@ -37,6 +51,9 @@ func (l List) Update(msg tea.Msg) (List, tea.Cmd) {
 				l.cursor++
 			}
 		case "enter", " ":
+			if l.menu {
+				break
+			}
 			_, ok := l.selected[l.cursor]
 			if ok {
 				delete(l.selected, l.cursor)
@@ -55,6 +72,10 @@ func (l List) View() string {
 		if l.cursor == i {
 			cursor = ">"
 		}
+		if l.menu {
+			s += fmt.Sprintf("%s %s\n", cursor, choice)
+			continue
+		}
 		checked := " "
 		if _, ok := l.selected[i]; ok {
 			checked = "x"
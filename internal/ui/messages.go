@@ -5,6 +5,11 @@ type SessionUpdatedMsg struct {
 	SessionID string
 }
 
+// SessionOutputMsg is sent when sessions have produced new output
+type SessionOutputMsg struct {
+	SessionIDs []string
+}
+
 // ThemeChangedMsg is sent when the theme changes
 type ThemeChangedMsg struct {
 	Theme string
@@ -19,9 +24,16 @@ type CommandExecutedMsg struct {
 	Command   string
 }
 
-// TabClosedMsg is sent when a tab is closed
+// TabClosedMsg is sent when a tab is closed and its processes have ended
 type TabClosedMsg struct {
 	SessionID string
+	Err       error // Processes that had to be terminated, if any
+}
+
+// TabRestartedMsg is sent when restarting a tab's command has finished
+type TabRestartedMsg struct {
+	SessionID string
+	Err       error // Why the command could not be started, if it was not
 }
 
 // NewTabMsg is sent when a new tab is requested
@@ -29,3 +41,6 @@ type NewTabMsg struct{}
 
 // QuitMsg is sent when quit is requested
 type QuitMsg struct{}
+
+// DetachMsg is sent to detach the client, leaving its sessions running
+type DetachMsg struct{}
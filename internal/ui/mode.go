@@ -0,0 +1,43 @@
+package ui
+
+import "github.com/charmbracelet/lipgloss"
+
+// InputMode is the state of the keyboard input state machine
+type InputMode int
+
+const (
+	// PassthroughMode sends every key to the active session, except the
+	// prefix key and the reserved chords
+	PassthroughMode InputMode = iota
+	// CommandMode interprets the next key as a terbox command
+	CommandMode
+)
+
+// modeIndicatorWidth is the width of the mode indicator in the tab bar row
+const modeIndicatorWidth = 9
+
+// String returns the label shown in the mode indicator
+func (m InputMode) String() string {
+	switch m {
+	case CommandMode:
+		return "COMMAND"
+	default:
+		return "TERMINAL"
+	}
+}
+
+// renderModeIndicator renders the current input mode for the tab bar row
+func renderModeIndicator(mode InputMode, theme *Theme) string {
+	style := theme.NewStyle().
+		Width(modeIndicatorWidth).
+		Align(lipgloss.Center)
+	if mode == CommandMode {
+		style = style.
+			Bold(true).
+			Foreground(lipgloss.Color("0")).
+			Background(lipgloss.Color(theme.TabFocusedFg))
+	} else {
+		style = style.Foreground(lipgloss.Color(theme.TabInactiveFg))
+	}
+	return style.Render(mode.String())
+}
@@ -0,0 +1,34 @@
+package ui
+
+import (
+	"terbox/internal/mux"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// outputFrameInterval is the minimum time between two SessionOutputMsg, so
+// that a flood of output is rendered at most once per frame
+const outputFrameInterval = time.Second / 60
+
+// outputListener turns multiplexer output notifications into messages
+type outputListener struct {
+	sub  *mux.Subscription
+	last time.Time
+}
+
+// listen returns a command waiting for the next batch of session output
+func (l *outputListener) listen() tea.Cmd {
+	return func() tea.Msg {
+		if _, ok := <-l.sub.Updates(); !ok {
+			// Unsubscribed when the app was closed
+			return nil
+		}
+		if wait := outputFrameInterval - time.Since(l.last); wait > 0 {
+			// Let more output accumulate into this update
+			time.Sleep(wait)
+		}
+		l.last = time.Now()
+		return SessionOutputMsg{SessionIDs: l.sub.TakeUpdated()}
+	}
+}
@@ -7,14 +7,17 @@ import (
 	"github.com/charmbracelet/lipgloss"
 )
 
+// panelMaxLines is the number of lines a panel keeps
+const panelMaxLines = 1000
+
 // Panel displays content with borders and styling
 type Panel struct {
-	title   string
-	content string
-	width   int
-	height  int
-	style   lipgloss.Style
-	theme   *Theme
+	title  string
+	lines  []string // content, at most panelMaxLines
+	width  int
+	height int
+	style  lipgloss.Style
+	theme  *Theme
 }
 
 // NewPanel creates a new panel with the given title and default theme
@@ -39,7 +42,8 @@ func (p *Panel) SetSize(width, height int) {
 
 // SetContent sets the content of the panel
 func (p *Panel) SetContent(content string) {
-	p.content = content
+	p.lines = nil
+	p.AppendLine(content)
 }
 
 // SetStyle sets the style of the panel
@@ -59,24 +63,18 @@ func (p *Panel) Update(msg tea.Msg) tea.Cmd {
 
 // AppendLine appends a line to the panel content
 func (p *Panel) AppendLine(line string) {
-	if p.content == "" {
-		p.content = line
-	} else {
-		p.content = p.content + "\n" + line
-	}
+	p.lines = append(p.lines, strings.Split(line, "\n")...)
 
-	// Keep only last N lines to avoid memory issues
-	lines := strings.Split(p.content, "\n")
-	maxLines := 1000
-	if len(lines) > maxLines {
-		lines = lines[len(lines)-maxLines:]
-		p.content = strings.Join(lines, "\n")
+	// Keep only last N lines to avoid memory issues. Dropped lines are
+	// released when append next moves the slice.
+	if len(p.lines) > panelMaxLines {
+		p.lines = p.lines[len(p.lines)-panelMaxLines:]
 	}
 }
 
 // ClearContent clears all panel content
 func (p *Panel) ClearContent() {
-	p.content = ""
+	p.lines = nil
 }
 
 // View renders the panel
@@ -87,13 +85,8 @@ func (p *Panel) View() string {
 	}
 
 	// Truncate or pad content lines
-	lines := strings.Split(p.content, "\n")
-	if len(lines) > contentHeight {
-		lines = lines[:contentHeight]
-	}
-	for len(lines) < contentHeight {
-		lines = append(lines, "")
-	}
+	lines := make([]string, contentHeight)
+	copy(lines, p.lines)
 
 	content := strings.Join(lines, "\n")
 	return content
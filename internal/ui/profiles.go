@@ -0,0 +1,51 @@
+package ui
+
+import (
+	"fmt"
+	"terbox/internal/data"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+)
+
+// profileMenu picks the profile to open a new tab with
+type profileMenu struct {
+	list     List
+	profiles []data.Profile
+}
+
+// newProfileMenu creates a menu of the configured profiles, marking the
+// default one
+func newProfileMenu(config *data.Config) *profileMenu {
+	choices := make([]string, len(config.Profiles))
+	for i, p := range config.Profiles {
+		choices[i] = p.Name
+		if p.Name == config.DefaultProfile {
+			choices[i] += " (default)"
+		}
+	}
+	return &profileMenu{list: NewMenu(choices), profiles: config.Profiles}
+}
+
+// update moves through the menu. It reports whether the menu is finished,
+// and the picked profile if one was picked.
+func (m *profileMenu) update(msg tea.KeyMsg) (done bool, picked *data.Profile) {
+	switch msg.String() {
+	case "enter":
+		return true, &m.profiles[m.list.Cursor()]
+	case "esc", "q", "ctrl+c":
+		return true, nil
+	}
+	m.list, _ = m.list.Update(msg)
+	return false, nil
+}
+
+// view renders the menu in a box in the middle of the screen
+func (m *profileMenu) view(width, height int, theme *Theme) string {
+	box := theme.NewStyle().
+		BorderStyle(lipgloss.RoundedBorder()).
+		BorderForeground(PrimaryColor).
+		Padding(1, 2).
+		Render(fmt.Sprintf("NEW TAB FROM PROFILE\n\n%s\nenter: open  esc: cancel", m.list.View()))
+	return lipgloss.Place(width, height, lipgloss.Center, lipgloss.Center, box)
+}
@@ -0,0 +1,149 @@
+package ui
+
+import (
+	"errors"
+	"flag"
+	"io"
+	"strings"
+	"terbox/internal/data"
+	"unicode"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+)
+
+// prompt is a line of text input shown under the tab bar
+type prompt struct {
+	label  string
+	value  []rune
+	submit func(a *App, value string) tea.Cmd
+}
+
+// update edits the prompt with a key. It reports whether the prompt is
+// finished, and whether it was submitted rather than cancelled.
+func (p *prompt) update(msg tea.KeyMsg) (done, submitted bool) {
+	switch msg.Type {
+	case tea.KeyEnter:
+		return true, true
+	case tea.KeyEsc, tea.KeyCtrlC:
+		return true, false
+	case tea.KeyBackspace:
+		if len(p.value) > 0 {
+			p.value = p.value[:len(p.value)-1]
+		}
+	case tea.KeyCtrlU:
+		p.value = nil
+	case tea.KeyCtrlW:
+		// Delete the word before the cursor, like a shell
+		end := len(p.value)
+		for end > 0 && unicode.IsSpace(p.value[end-1]) {
+			end--
+		}
+		for end > 0 && !unicode.IsSpace(p.value[end-1]) {
+			end--
+		}
+		p.value = p.value[:end]
+	case tea.KeySpace:
+		p.value = append(p.value, ' ')
+	case tea.KeyRunes:
+		p.value = append(p.value, msg.Runes...)
+	}
+	return false, false
+}
+
+// view renders the prompt with a cursor at the end of the input
+func (p *prompt) view(width int, theme *Theme) string {
+	label := theme.NewStyle().
+		Bold(true).
+		Foreground(lipgloss.Color(theme.TabFocusedFg)).
+		Render(" " + p.label + " ")
+	cursor := theme.NewStyle().Reverse(true).Render(" ")
+
+	// Keep the end of long input in view
+	value := p.value
+	if room := width - lipgloss.Width(label) - 1; len(value) > room {
+		value = value[len(value)-max(room, 0):]
+	}
+	return theme.NewStyle().
+		Width(width).
+		MaxWidth(width).
+		Render(label + string(value) + cursor)
+}
+
+// newTabPrompt asks for the command of a new tab, with the options of
+// "terbox new", e.g. "-c ~/proj/web npm run dev"
+func newTabPrompt() *prompt {
+	return &prompt{
+		label: "New tab:",
+		submit: func(a *App, value string) tea.Cmd {
+			args, err := splitWords(value)
+			if err != nil {
+				a.notice = err.Error()
+				return nil
+			}
+			fs := flag.NewFlagSet("new", flag.ContinueOnError)
+			fs.SetOutput(io.Discard)
+			var opts data.SessionOptions
+			opts.AddFlags(fs)
+			if err := fs.Parse(args); err != nil {
+				a.notice = "usage: [-c dir] [-e NAME=VALUE] [-u NAME] [-t title] [-l] [command [args...]]: " + err.Error()
+				return nil
+			}
+			opts.Command = fs.Args()
+			return a.createSession(opts)
+		},
+	}
+}
+
+// splitWords splits a command line into words like a shell, honoring
+// single quotes, double quotes and backslash escapes
+func splitWords(s string) ([]string, error) {
+	var words []string
+	var word strings.Builder
+	inWord := false
+	var quote rune
+	escaped := false
+
+	for _, r := range s {
+		switch {
+		case escaped:
+			word.WriteRune(r)
+			escaped = false
+		case quote == '\'':
+			if r == '\'' {
+				quote = 0
+			} else {
+				word.WriteRune(r)
+			}
+		case r == '\\' && quote != '\'':
+			escaped = true
+			inWord = true
+		case quote == '"':
+			if r == '"' {
+				quote = 0
+			} else {
+				word.WriteRune(r)
+			}
+		case r == '\'' || r == '"':
+			quote = r
+			inWord = true
+		case unicode.IsSpace(r):
+			if inWord {
+				words = append(words, word.String())
+				word.Reset()
+				inWord = false
+			}
+		default:
+			word.WriteRune(r)
+			inWord = true
+		}
+	}
+
+	if quote != 0 || escaped {
+		return nil, errors.New("unterminated quote or escape")
+	}
+	if inWord {
+		words = append(words, word.String())
+	}
+	return words, nil
+}
@@ -0,0 +1,150 @@
+package ui
+
+import (
+	"fmt"
+	"regexp"
+	"terbox/internal/data"
+	"terbox/internal/vt"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+)
+
+// searchMode is how a search query is matched
+type searchMode int
+
+const (
+	searchLiteral searchMode = iota
+	searchIgnoreCase
+	searchRegex
+)
+
+// String returns the name shown in the search prompt
+func (m searchMode) String() string {
+	switch m {
+	case searchIgnoreCase:
+		return "ignore case"
+	case searchRegex:
+		return "regex"
+	default:
+		return "literal"
+	}
+}
+
+// compile turns a query into a regular expression for the mode
+func (m searchMode) compile(query string) (*regexp.Regexp, error) {
+	switch m {
+	case searchIgnoreCase:
+		return regexp.Compile("(?i)" + regexp.QuoteMeta(query))
+	case searchRegex:
+		return regexp.Compile(query)
+	default:
+		return regexp.Compile(regexp.QuoteMeta(query))
+	}
+}
+
+// search is an incremental search through the screen and scrollback of a
+// session. Matches are found again as the query changes.
+type search struct {
+	session *data.TerminalSession
+	input   prompt
+	mode    searchMode
+	matches []vt.Match
+	current int   // index of the selected match, -1 if none
+	err     error // why the query is not a valid regex
+}
+
+// newSearch starts a search in a session
+func newSearch(session *data.TerminalSession) *search {
+	return &search{session: session, current: -1}
+}
+
+// update handles a key. It reports whether the search is finished, and
+// whether it was cancelled rather than accepted.
+func (s *search) update(msg tea.KeyMsg) (done, cancelled bool) {
+	switch msg.Type {
+	case tea.KeyEnter:
+		return true, false
+	case tea.KeyEsc, tea.KeyCtrlC:
+		return true, true
+	case tea.KeyUp, tea.KeyCtrlP:
+		s.step(-1)
+	case tea.KeyDown, tea.KeyCtrlN:
+		s.step(1)
+	case tea.KeyCtrlR:
+		s.mode = (s.mode + 1) % (searchRegex + 1)
+		s.run()
+	default:
+		before := string(s.input.value)
+		s.input.update(msg)
+		if string(s.input.value) != before {
+			s.run()
+		}
+	}
+	return false, false
+}
+
+// run finds the matches of the query and selects the newest one
+func (s *search) run() {
+	s.matches, s.current, s.err = nil, -1, nil
+	if len(s.input.value) == 0 {
+		return
+	}
+	re, err := s.mode.compile(string(s.input.value))
+	if err != nil {
+		s.err = err
+		return
+	}
+	s.matches = s.session.Screen.Search(re)
+	s.step(0)
+}
+
+// step selects the match dir matches older (negative) or newer
+// (positive) than the current one, wrapping around, and scrolls to it.
+// A dir of 0 selects the newest match.
+func (s *search) step(dir int) {
+	n := len(s.matches)
+	if n == 0 {
+		return
+	}
+	if s.current < 0 || dir == 0 {
+		s.current = n - 1
+	} else {
+		s.current = (s.current + dir + n) % n
+	}
+	s.session.ScrollToLine(s.matches[s.current].Line)
+}
+
+// selectMatch selects a match and scrolls to it, if it is among the
+// matches found
+func (s *search) selectMatch(match vt.Match) {
+	for i, m := range s.matches {
+		if m == match {
+			s.current = i
+			s.session.ScrollToLine(m.Line)
+			return
+		}
+	}
+}
+
+// view renders the query with the mode and a match counter
+func (s *search) view(width int, theme *Theme) string {
+	var status string
+	switch {
+	case s.err != nil:
+		status = "invalid regex"
+	case len(s.input.value) == 0:
+		status = "ctrl+r: mode"
+	case len(s.matches) == 0:
+		status = "no matches"
+	default:
+		status = fmt.Sprintf("%d/%d", s.current+1, len(s.matches))
+	}
+	status = theme.NewStyle().
+		Foreground(lipgloss.Color(theme.TabInactiveFg)).
+		Render(" " + status + " ")
+
+	s.input.label = fmt.Sprintf("Search (%s):", s.mode)
+	input := s.input.view(max(width-lipgloss.Width(status), 0), theme)
+	return input + status
+}
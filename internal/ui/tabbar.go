@@ -7,6 +7,7 @@ import (
 
 	tea "github.com/charmbracelet/bubbletea"
 	"github.com/charmbracelet/lipgloss"
+	"github.com/mattn/go-runewidth"
 )
 
 // Tab represents a single tab
@@ -46,19 +47,20 @@ func NewTabBar(tabs []Tab) *TabBar {
 	}
 }
 
-// NewTabBarWithMux creates a new tab bar with multiplexer
-func NewTabBarWithMux(m *mux.Multiplexer) *TabBar {
+// NewTabBarWithMux creates a new tab bar with multiplexer, styled with
+// the renderer of a theme
+func NewTabBarWithMux(m *mux.Multiplexer, theme *Theme) *TabBar {
 	return &TabBar{
 		mux:       m,
 		tabs:      []Tab{},
 		active:    0,
 		activeIdx: 0,
-		style:     lipgloss.NewStyle(),
-		activeStyle: lipgloss.NewStyle().
+		style:     theme.NewStyle(),
+		activeStyle: theme.NewStyle().
 			Foreground(lipgloss.Color("255")).
 			Background(lipgloss.Color("63")).
 			Padding(0, 1),
-		inactiveStyle: lipgloss.NewStyle().
+		inactiveStyle: theme.NewStyle().
 			Foreground(lipgloss.Color("240")).
 			Padding(0, 1),
 	}
@@ -70,7 +72,8 @@ func (tb *TabBar) Init() tea.Cmd {
 	return nil
 }
 
-// Update handles key inputs and mouse clicks for tab navigation
+// Update handles mouse clicks for tab navigation. Keys are interpreted by
+// the app, which calls NextTab, PrevTab and SelectTab.
 func (tb *TabBar) Update(msg tea.Msg) tea.Cmd {
 	if tb == nil {
 		return nil
@@ -84,23 +87,6 @@ func (tb *TabBar) Update(msg tea.Msg) tea.Cmd {
 		tb.SetSize(msg.Width, msg.Height)
 	case SessionUpdatedMsg:
 		tb.UpdateSessions()
-	case tea.KeyMsg:
-		switch msg.String() {
-		case "right", "l":
-			if tb.active < len(tb.tabs)-1 {
-				tb.active++
-			}
-		case "left", "h":
-			if tb.active > 0 {
-				tb.active--
-			}
-		case "1", "2", "3", "4", "5", "6", "7", "8", "9":
-			// Number shortcuts: 1 = first tab, 2 = second tab, etc.
-			tabNum := int(msg.String()[0]-'0') - 1
-			if tabNum >= 0 && tabNum < len(tb.tabs) {
-				tb.active = tabNum
-			}
-		}
 	case tea.MouseMsg:
 		// Handle mouse clicks on tabs
 		if msg.Type == tea.MouseLeft {
@@ -109,19 +95,11 @@ func (tb *TabBar) Update(msg tea.Msg) tea.Cmd {
 				var tabWidth int
 				if tb.mux != nil {
 					// Use session label width: " [index] name "
-					name := ""
+					var info *mux.SessionInfo
 					if i < len(tb.sessions) {
-						info := tb.mux.GetSessionInfo(tb.sessions[i])
-						if info != nil {
-							if info.LastCommand != "" {
-								name = truncateStr(info.LastCommand, 20)
-							} else {
-								name = info.Name
-							}
-						}
+						info = tb.mux.GetSessionInfo(tb.sessions[i])
 					}
-					tabLabel := fmt.Sprintf(" [%d] %s ", i+1, name)
-					tabWidth = lipgloss.Width(tabLabel)
+					tabWidth = lipgloss.Width(sessionLabel(i, info))
 				} else {
 					if i < len(tb.tabs) {
 						tabWidth = len(tb.tabs[i].Title) + 2
@@ -164,17 +142,20 @@ func (tb *TabBar) View() string {
 				continue
 			}
 
-			tabName := info.Name
-			if info.LastCommand != "" {
-				tabName = truncateStr(info.LastCommand, 20)
-			}
-
-			tabLabel := fmt.Sprintf(" [%d] %s ", i+1, tabName)
+			tabLabel := sessionLabel(i, info)
 			var renderedTab string
 			if i == tb.activeIdx {
-				renderedTab = tb.activeStyle.Render(tabLabel)
+				style := tb.activeStyle
+				if info.Color != "" {
+					style = style.Background(lipgloss.Color(info.Color))
+				}
+				renderedTab = style.Render(tabLabel)
 			} else {
-				renderedTab = tb.inactiveStyle.Render(tabLabel)
+				style := tb.inactiveStyle
+				if info.Color != "" {
+					style = style.Foreground(lipgloss.Color(info.Color))
+				}
+				renderedTab = style.Render(tabLabel)
 			}
 			tabStrings = append(tabStrings, renderedTab)
 			currentPos += lipgloss.Width(renderedTab)
@@ -228,12 +209,32 @@ func (tb *TabBar) UpdateSessions() {
 	}
 }
 
-// truncateStr truncates a string to a maximum length
-func truncateStr(s string, maxLen int) string {
-	if len(s) > maxLen {
-		return s[:maxLen-3] + "..."
+// sessionLabel returns the label of the i-th session tab, such as
+// " [1] vim ", with a marker and the status once the process has exited.
+// Without a title, e.g. once a foreground job has exited, the tab goes
+// back to the session name.
+func sessionLabel(i int, info *mux.SessionInfo) string {
+	if info == nil {
+		return fmt.Sprintf(" [%d]  ", i+1)
 	}
-	return s
+
+	name := info.Name
+	if info.Title != "" {
+		name = truncateStr(info.Title, 20)
+	}
+	if info.Exit != nil {
+		status := info.Exit.Signal
+		if status == "" {
+			status = fmt.Sprint(info.Exit.Code)
+		}
+		name += " ✗ " + status
+	}
+	return fmt.Sprintf(" [%d] %s ", i+1, name)
+}
+
+// truncateStr truncates a string to a maximum display width
+func truncateStr(s string, maxLen int) string {
+	return runewidth.Truncate(s, maxLen, "...")
 }
 
 // ActiveTab returns the currently active tab
@@ -329,6 +330,17 @@ func (tb *TabBar) SelectTab(idx int) {
 	}
 }
 
+// SelectSession switches to the tab of a session
+func (tb *TabBar) SelectSession(id string) {
+	tb.UpdateSessions()
+	for i, sid := range tb.sessions {
+		if sid == id {
+			tb.SelectTab(i)
+			return
+		}
+	}
+}
+
 // GetActiveSessionID returns the active session ID
 func (tb *TabBar) GetActiveSessionID() string {
 	if tb.activeIdx >= 0 && tb.activeIdx < len(tb.sessions) {
@@ -1,24 +1,35 @@
 package ui
 
 import (
+	"sort"
 	"strings"
+	"terbox/internal/data"
+	"terbox/internal/vt"
 
 	tea "github.com/charmbracelet/bubbletea"
 	"github.com/charmbracelet/lipgloss"
 )
 
-// Terminal represents an interactive terminal panel
+// Terminal renders a terminal session. The screen, scrollback and scroll
+// offset belong to the session, so switching sessions restores each view.
 type Terminal struct {
-	width        int
-	height       int
-	content      []string // Lines of content/history
-	inputBuffer  string   // Current input line being typed
-	scrollOffset int      // For scrolling through history
-	theme        *Theme
-	style        lipgloss.Style
-	maxLines     int // Maximum lines to keep in history (default 1000)
+	width      int
+	height     int
+	session    *data.TerminalSession // Session being displayed
+	ownSession *data.TerminalSession // Detached session shown when none is set
+	theme      *Theme
+	style      lipgloss.Style
+	search     *search   // Highlights the matches of a search in its session
+	copy       *copyMode // Shows the cursor and selection of copy mode in its session
 }
 
+// Styles of search matches, of the selected one and of copy mode selections
+var (
+	matchStyle        = vt.Style{Fg: vt.IndexedColor(0), Bg: vt.IndexedColor(11)}
+	currentMatchStyle = vt.Style{Fg: vt.IndexedColor(0), Bg: vt.IndexedColor(208)}
+	selectionStyle    = vt.Style{Fg: vt.IndexedColor(0), Bg: vt.IndexedColor(14)}
+)
+
 // NewTerminal creates a new terminal with default theme
 func NewTerminal() *Terminal {
 	return NewTerminalWithTheme(DefaultTheme())
@@ -26,11 +37,12 @@ func NewTerminal() *Terminal {
 
 // NewTerminalWithTheme creates a new terminal with a custom theme
 func NewTerminalWithTheme(theme *Theme) *Terminal {
+	session := data.NewTerminalSession("", "")
 	return &Terminal{
-		content:  []string{""},
-		theme:    theme,
-		style:    theme.GetPanelStyle(),
-		maxLines: 1000,
+		session:    session,
+		ownSession: session,
+		theme:      theme,
+		style:      theme.GetPanelStyle(),
 	}
 }
 
@@ -38,6 +50,12 @@ func NewTerminalWithTheme(theme *Theme) *Terminal {
 func (t *Terminal) SetSize(width, height int) {
 	t.width = width
 	t.height = height
+	t.session.Resize(t.ScreenSize())
+}
+
+// ScreenSize returns the size available to the session's screen
+func (t *Terminal) ScreenSize() (cols, rows int) {
+	return t.width, t.height
 }
 
 // Init returns no command
@@ -45,28 +63,23 @@ func (t *Terminal) Init() tea.Cmd {
 	return nil
 }
 
-// Update handles input for the terminal
+// Update forwards keystrokes to the session, encoded as an xterm would
 func (t *Terminal) Update(msg tea.Msg) tea.Cmd {
 	switch msg := msg.(type) {
 	case tea.KeyMsg:
-		switch msg.Type {
-		case tea.KeyEnter:
-			// Execute command (add to history)
-			t.ExecuteCommand(t.inputBuffer)
-			t.inputBuffer = ""
-		case tea.KeyBackspace:
-			if len(t.inputBuffer) > 0 {
-				t.inputBuffer = t.inputBuffer[:len(t.inputBuffer)-1]
-			}
-		case tea.KeyUp:
+		screen := t.session.Screen
+		input := encodeKey(msg, screen.Mode(vt.ModeAppCursor), screen.Mode(vt.ModeBracketedPaste))
+		if len(input) > 0 {
+			// Typing returns the view to the live screen
+			t.session.SetScrollOffset(0)
+			t.session.WriteInput(input)
+		}
+	case tea.MouseMsg:
+		switch msg.Button {
+		case tea.MouseButtonWheelUp:
 			t.scrollUp()
-		case tea.KeyDown:
+		case tea.MouseButtonWheelDown:
 			t.scrollDown()
-		default:
-			// Add regular character input
-			if len(msg.String()) == 1 {
-				t.inputBuffer += msg.String()
-			}
 		}
 	}
 	return nil
@@ -74,127 +87,210 @@ func (t *Terminal) Update(msg tea.Msg) tea.Cmd {
 
 // View renders the terminal
 func (t *Terminal) View() string {
-	contentHeight := t.height - 1 // Reserve 1 line for input
-	if contentHeight < 1 {
-		contentHeight = 1
+	screen := t.session.Screen
+	offset := t.session.ScrollOffset()
+	lines, first := screen.NumberedViewport(offset)
+	t.highlightMatches(lines, first)
+	cursorX, cursorY, cursorVisible := screen.Cursor()
+	if !cursorVisible || offset > 0 {
+		cursorY = -1
 	}
-
-	// Get visible lines from content
-	visibleLines := []string{}
-	startIdx := len(t.content) - contentHeight - t.scrollOffset
-	if startIdx < 0 {
-		startIdx = 0
-	}
-	endIdx := startIdx + contentHeight
-
-	if endIdx > len(t.content) {
-		endIdx = len(t.content)
+	if c := t.copy; c != nil && c.session == t.session {
+		t.highlightSelection(lines, first)
+		cursorX, cursorY = c.cursor.Col, -1
+		if c.cursor.Line >= first && c.cursor.Line-first < uint64(len(lines)) {
+			cursorY = int(c.cursor.Line - first)
+		}
 	}
+	reverse := screen.Mode(vt.ModeReverseVideo)
 
-	for i := startIdx; i < endIdx; i++ {
-		if i >= 0 && i < len(t.content) {
-			visibleLines = append(visibleLines, t.truncateLine(t.content[i], t.width))
+	visibleLines := make([]string, 0, t.height)
+	for y := 0; y < len(lines) && y < t.height; y++ {
+		cx := -1
+		if y == cursorY {
+			cx = cursorX
 		}
+		visibleLines = append(visibleLines, renderLine(lines[y], t.width, cx, reverse))
 	}
 
 	// Pad to fill height
-	for len(visibleLines) < contentHeight {
+	for len(visibleLines) < t.height {
 		visibleLines = append(visibleLines, "")
 	}
 
-	// Build output
-	output := strings.Join(visibleLines, "\n")
+	return strings.Join(visibleLines, "\n")
+}
 
-	// Add input line
-	inputLine := "$ " + t.inputBuffer + "_"
-	if len(inputLine) > t.width {
-		inputLine = inputLine[:t.width]
+// highlightMatches restyles the cells of search matches in the lines of a
+// viewport starting at line number first
+func (t *Terminal) highlightMatches(lines []vt.Line, first uint64) {
+	s := t.search
+	if s == nil || s.session != t.session {
+		return
 	}
+	// Matches are in line order; skip to the first one in view
+	i := sort.Search(len(s.matches), func(i int) bool { return s.matches[i].Line >= first })
+	for ; i < len(s.matches); i++ {
+		m := s.matches[i]
+		if m.Line-first >= uint64(len(lines)) {
+			break
+		}
+		y := int(m.Line - first)
+		style := matchStyle
+		if i == s.current {
+			style = currentMatchStyle
+		}
+		cells := lines[y].Cells
+		for x := m.Col; x < m.End && x < len(cells); x++ {
+			cells[x].Fg, cells[x].Bg = style.Fg, style.Bg
+			cells[x].Attr &^= vt.AttrReverse | vt.AttrInvisible
+		}
+	}
+}
 
-	result := output + "\n" + inputLine
-	return result
+// highlightSelection restyles the cells selected in copy mode in the lines
+// of a viewport starting at line number first
+func (t *Terminal) highlightSelection(lines []vt.Line, first uint64) {
+	for y := range lines {
+		lo, hi, ok := t.copy.span(first + uint64(y))
+		if !ok {
+			continue
+		}
+		// Spilled lines may be shorter than the screen
+		for len(lines[y].Cells) <= hi && len(lines[y].Cells) < t.width {
+			lines[y].Cells = append(lines[y].Cells, vt.Cell{Width: 1})
+		}
+		cells := lines[y].Cells
+		for x := max(lo, 0); x <= hi && x < len(cells); x++ {
+			cells[x].Fg, cells[x].Bg = selectionStyle.Fg, selectionStyle.Bg
+			cells[x].Attr &^= vt.AttrReverse | vt.AttrInvisible
+		}
+	}
 }
 
-// ExecuteCommand adds a command and its output to the terminal
-func (t *Terminal) ExecuteCommand(command string) {
-	// Add command to history
-	t.content = append(t.content, "$ "+command)
+// renderLine renders the cells of a line padded to width. The cell at
+// cursorX (if not negative) is drawn in reverse video as the cursor.
+func renderLine(line vt.Line, width, cursorX int, reverse bool) string {
+	var sb strings.Builder
+	var current vt.Style
+	for x := 0; x < width; x++ {
+		cell := vt.Cell{Width: 1}
+		if x < len(line.Cells) {
+			cell = line.Cells[x]
+		}
+		if cell.Width == 0 {
+			// right half of a wide character
+			continue
+		}
+		if cell.Width == 2 && x == width-1 {
+			cell = vt.Cell{Width: 1, Style: cell.Style}
+		}
 
-	// Trim content if exceeds maxLines
-	if len(t.content) > t.maxLines {
-		t.content = t.content[len(t.content)-t.maxLines:]
+		style := cell.Style
+		if reverse {
+			style.Attr ^= vt.AttrReverse
+		}
+		if x == cursorX {
+			style.Attr ^= vt.AttrReverse
+		}
+		if style != current {
+			sb.WriteString(style.SGR())
+			current = style
+		}
+		if cell.Rune == 0 || style.Attr&vt.AttrInvisible != 0 {
+			sb.WriteByte(' ')
+			if cell.Width == 2 {
+				sb.WriteByte(' ')
+			}
+		} else {
+			sb.WriteRune(cell.Rune)
+		}
 	}
+	if current != (vt.Style{}) {
+		sb.WriteString("\x1b[0m")
+	}
+	return sb.String()
+}
 
-	// Reset scroll offset when new command is executed
-	t.scrollOffset = 0
+// ExecuteCommand sends a command line to the session
+func (t *Terminal) ExecuteCommand(command string) error {
+	t.session.SetScrollOffset(0)
+	return t.session.WriteCommand(command)
 }
 
 // WriteOutput writes output to the terminal
 func (t *Terminal) WriteOutput(output string) {
-	lines := strings.Split(output, "\n")
-	for _, line := range lines {
-		t.content = append(t.content, line)
-	}
-
-	// Trim content if exceeds maxLines
-	if len(t.content) > t.maxLines {
-		t.content = t.content[len(t.content)-t.maxLines:]
-	}
+	t.session.Screen.Write([]byte(output))
+	t.session.SetScrollOffset(0)
+}
 
-	t.scrollOffset = 0
+// Write feeds raw program output to the terminal emulator
+func (t *Terminal) Write(p []byte) (int, error) {
+	t.session.SetScrollOffset(0)
+	return t.session.Screen.Write(p)
 }
 
 // ClearContent clears all terminal content
 func (t *Terminal) ClearContent() {
-	t.content = []string{""}
-	t.inputBuffer = ""
-	t.scrollOffset = 0
-}
-
-// GetInputBuffer returns the current input being typed
-func (t *Terminal) GetInputBuffer() string {
-	return t.inputBuffer
-}
-
-// SetInputBuffer sets the input buffer manually
-func (t *Terminal) SetInputBuffer(input string) {
-	t.inputBuffer = input
+	// Full reset followed by erasing the scrollback
+	t.session.Screen.Write([]byte("\x1bc\x1b[3J"))
+	t.session.SetScrollOffset(0)
 }
 
 // GetContent returns all terminal content
 func (t *Terminal) GetContent() []string {
-	return t.content
+	lines := t.session.Screen.Lines()
+	content := make([]string, len(lines))
+	for i, line := range lines {
+		content[i] = line.String()
+	}
+	return content
 }
 
 // GetHistory returns formatted history as a single string
 func (t *Terminal) GetHistory() string {
-	return strings.Join(t.content, "\n")
+	return strings.Join(t.GetContent(), "\n")
 }
 
-// scrollUp scrolls up through history
-func (t *Terminal) scrollUp() {
-	maxScroll := len(t.content) - (t.height - 1)
-	if maxScroll < 0 {
-		maxScroll = 0
+// SetSession displays another session in the terminal. A nil session
+// restores the terminal's own detached session.
+func (t *Terminal) SetSession(session *data.TerminalSession) {
+	if session == nil {
+		session = t.ownSession
+	}
+	if session == t.session {
+		return
 	}
-	if t.scrollOffset < maxScroll {
-		t.scrollOffset++
+	t.session = session
+	if t.width > 0 && t.height > 0 {
+		t.session.Resize(t.ScreenSize())
 	}
 }
 
+// GetSession returns the session being displayed
+func (t *Terminal) GetSession() *data.TerminalSession {
+	return t.session
+}
+
+// GetScreen returns the emulated screen
+func (t *Terminal) GetScreen() *vt.Screen {
+	return t.session.Screen
+}
+
+// scrollUp scrolls up through history
+func (t *Terminal) scrollUp() {
+	t.session.ScrollBy(1)
+}
+
 // scrollDown scrolls down through history
 func (t *Terminal) scrollDown() {
-	if t.scrollOffset > 0 {
-		t.scrollOffset--
-	}
+	t.session.ScrollBy(-1)
 }
 
-// truncateLine truncates a line to fit within the terminal width
-func (t *Terminal) truncateLine(line string, width int) string {
-	if len(line) > width {
-		return line[:width]
-	}
-	return line
+// jumpToPrompt scrolls to the previous (dir < 0) or next (dir > 0) shell
+// prompt marked by shell integration
+func (t *Terminal) jumpToPrompt(dir int) {
+	t.session.JumpToPrompt(dir)
 }
 
 // SetTheme sets the theme for the terminal
@@ -210,13 +306,10 @@ func (t *Terminal) GetTheme() *Theme {
 
 // SetMaxLines sets the maximum number of lines to keep in history
 func (t *Terminal) SetMaxLines(max int) {
-	t.maxLines = max
-	if len(t.content) > max {
-		t.content = t.content[len(t.content)-max:]
-	}
+	t.session.Screen.SetScrollbackLimit(max)
 }
 
 // GetMaxLines returns the maximum number of lines
 func (t *Terminal) GetMaxLines() int {
-	return t.maxLines
+	return t.session.Screen.ScrollbackLimit()
 }
@@ -20,6 +20,24 @@ type Theme struct {
 	// General colors
 	SeparatorColor  string
 	BackgroundColor string
+
+	// renderer builds the styles, for the color profile of the terminal
+	// they are shown on; nil means lipgloss's default renderer
+	renderer *lipgloss.Renderer
+}
+
+// SetRenderer makes the theme's styles render for one terminal. A server
+// shows several clients at once, each with its own color profile.
+func (t *Theme) SetRenderer(r *lipgloss.Renderer) {
+	t.renderer = r
+}
+
+// NewStyle returns an empty style for the theme's renderer
+func (t *Theme) NewStyle() lipgloss.Style {
+	if t.renderer == nil {
+		return lipgloss.NewStyle()
+	}
+	return t.renderer.NewStyle()
 }
 
 // DefaultTheme returns the default color scheme
@@ -90,7 +108,7 @@ func LightTheme() *Theme {
 
 // GetTabActiveStyle returns the style for active tabs
 func (t *Theme) GetTabActiveStyle() lipgloss.Style {
-	return lipgloss.NewStyle().
+	return t.NewStyle().
 		Foreground(lipgloss.Color(t.TabActiveFg)).
 		Background(lipgloss.Color(t.TabActiveBg)).
 		Padding(0, 1)
@@ -98,20 +116,20 @@ func (t *Theme) GetTabActiveStyle() lipgloss.Style {
 
 // GetTabInactiveStyle returns the style for inactive tabs
 func (t *Theme) GetTabInactiveStyle() lipgloss.Style {
-	return lipgloss.NewStyle().
+	return t.NewStyle().
 		Foreground(lipgloss.Color(t.TabInactiveFg)).
 		Padding(0, 1)
 }
 
 // GetTabFocusedStyle returns the style for focused tabs
 func (t *Theme) GetTabFocusedStyle() lipgloss.Style {
-	return lipgloss.NewStyle().
+	return t.NewStyle().
 		Foreground(lipgloss.Color(t.TabFocusedFg))
 }
 
 // GetPanelStyle returns the style for panels
 func (t *Theme) GetPanelStyle() lipgloss.Style {
-	return lipgloss.NewStyle().
+	return t.NewStyle().
 		Foreground(lipgloss.Color(t.PanelFg))
 }
 
@@ -0,0 +1,158 @@
+package vt
+
+import "strings"
+
+// Color is a terminal color: the default color, an entry of the 256 color
+// palette or a 24-bit RGB value
+type Color uint32
+
+const (
+	// DefaultColor is the terminal's default foreground or background
+	DefaultColor Color = 0
+
+	colorIndexed Color = 1 << 24
+	colorRGB     Color = 2 << 24
+	colorKind    Color = 3 << 24
+)
+
+// IndexedColor returns a palette color (0-15 are the ANSI colors)
+func IndexedColor(index uint8) Color {
+	return colorIndexed | Color(index)
+}
+
+// RGBColor returns a 24-bit color
+func RGBColor(r, g, b uint8) Color {
+	return colorRGB | Color(r)<<16 | Color(g)<<8 | Color(b)
+}
+
+// IsDefault reports whether c is the default color
+func (c Color) IsDefault() bool {
+	return c&colorKind == 0
+}
+
+// Index returns the palette index of an indexed color
+func (c Color) Index() (uint8, bool) {
+	if c&colorKind != colorIndexed {
+		return 0, false
+	}
+	return uint8(c), true
+}
+
+// RGB returns the components of a 24-bit color
+func (c Color) RGB() (r, g, b uint8, ok bool) {
+	if c&colorKind != colorRGB {
+		return 0, 0, 0, false
+	}
+	return uint8(c >> 16), uint8(c >> 8), uint8(c), true
+}
+
+// Attr is a set of text attributes
+type Attr uint16
+
+const (
+	AttrBold Attr = 1 << iota
+	AttrFaint
+	AttrItalic
+	AttrUnderline
+	AttrBlink
+	AttrReverse
+	AttrInvisible
+	AttrStrike
+)
+
+// Style is the rendition of a cell
+type Style struct {
+	Fg   Color
+	Bg   Color
+	Attr Attr
+}
+
+// Cell is a single character position on the screen
+type Cell struct {
+	Rune  rune  // 0 for a blank cell
+	Width uint8 // 1, 2 for a wide character, 0 for the right half of one
+	Style
+}
+
+// blankCell returns an erased cell carrying the background of style
+func blankCell(style Style) Cell {
+	return Cell{Width: 1, Style: Style{Bg: style.Bg}}
+}
+
+// Line is one row of cells
+type Line struct {
+	Cells   []Cell
+	Wrapped bool // the text continues on the next line (soft wrap)
+	Prompt  bool // a shell prompt starts on this line (OSC 133 A)
+}
+
+// newLine returns a blank line of the given width
+func newLine(width int, style Style) Line {
+	cells := make([]Cell, width)
+	blank := blankCell(style)
+	for i := range cells {
+		cells[i] = blank
+	}
+	return Line{Cells: cells}
+}
+
+// clone returns a deep copy of the line
+func (l Line) clone() Line {
+	cells := make([]Cell, len(l.Cells))
+	copy(cells, l.Cells)
+	return Line{Cells: cells, Wrapped: l.Wrapped, Prompt: l.Prompt}
+}
+
+// Len returns the number of cells up to the last non-blank one
+func (l Line) Len() int {
+	n := len(l.Cells)
+	for n > 0 && l.Cells[n-1].Rune == 0 && l.Cells[n-1].Width != 0 {
+		n--
+	}
+	return n
+}
+
+// String returns the text of the line without trailing blanks
+func (l Line) String() string {
+	var sb strings.Builder
+	n := l.Len()
+	for i := 0; i < n; i++ {
+		c := l.Cells[i]
+		switch {
+		case c.Width == 0:
+			// right half of a wide character
+		case c.Rune == 0:
+			sb.WriteByte(' ')
+		default:
+			sb.WriteRune(c.Rune)
+		}
+	}
+	return sb.String()
+}
+
+// ANSI returns the text of the line with SGR sequences for its styles,
+// ending with an attribute reset when any style was emitted
+func (l Line) ANSI() string {
+	var sb strings.Builder
+	var cur Style
+	n := l.Len()
+	for i := 0; i < n; i++ {
+		c := l.Cells[i]
+		if c.Width == 0 {
+			continue
+		}
+		if c.Style != cur {
+			sb.WriteString(c.Style.SGR())
+			cur = c.Style
+		}
+		if c.Rune == 0 {
+			sb.WriteByte(' ')
+		} else {
+			sb.WriteRune(c.Rune)
+		}
+	}
+	if cur != (Style{}) {
+		sb.WriteString("\x1b[0m")
+	}
+	return sb.String()
+}
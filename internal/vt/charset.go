@@ -0,0 +1,46 @@
+package vt
+
+// charset is a character set designated into one of G0-G3
+type charset uint8
+
+const (
+	charsetASCII      charset = iota
+	charsetDECSpecial         // DEC special graphics (line drawing)
+)
+
+// decSpecialGraphics maps 0x5f-0x7e to the DEC special graphics glyphs
+var decSpecialGraphics = [...]rune{
+	' ', '◆', '▒', '␉', '␌', '␍', '␊', '°', '±', '␤', '␋', '┘', '┐', '┌', '└', '┼',
+	'⎺', '⎻', '─', '⎼', '⎽', '├', '┤', '┴', '┬', '│', '≤', '≥', 'π', '≠', '£', '·',
+}
+
+// translate maps a printed character through the character set
+func (c charset) translate(r rune) rune {
+	if c == charsetDECSpecial && r >= 0x5f && r <= 0x7e {
+		return decSpecialGraphics[r-0x5f]
+	}
+	return r
+}
+
+// designateCharset handles ESC ( / ) / * / + followed by a final byte
+func (s *Screen) designateCharset(slot byte, final byte) {
+	var g int
+	switch slot {
+	case '(':
+		g = 0
+	case ')':
+		g = 1
+	case '*':
+		g = 2
+	case '+':
+		g = 3
+	default:
+		return
+	}
+	switch final {
+	case '0':
+		s.charsets[g] = charsetDECSpecial
+	default:
+		s.charsets[g] = charsetASCII
+	}
+}
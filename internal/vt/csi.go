@@ -0,0 +1,346 @@
+package vt
+
+import "fmt"
+
+// param returns the first value of parameter i, or def when it is omitted
+// or zero
+func param(params [][]int, i, def int) int {
+	if i >= len(params) || params[i][0] <= 0 {
+		return def
+	}
+	return params[i][0]
+}
+
+// escDispatch handles an escape sequence ESC [intermediates] final
+func (s *Screen) escDispatch(final byte) {
+	p := &s.parser
+	if len(p.intermediate) > 0 {
+		switch p.intermediate[0] {
+		case '(', ')', '*', '+':
+			s.designateCharset(p.intermediate[0], final)
+		case '#':
+			if final == '8' {
+				s.screenAlignment()
+			}
+		}
+		return
+	}
+
+	switch final {
+	case '7': // DECSC
+		s.saveCursor()
+	case '8': // DECRC
+		s.restoreCursor()
+	case 'D': // IND
+		s.index()
+	case 'E': // NEL
+		s.cur.x = 0
+		s.index()
+	case 'H': // HTS
+		s.tabs[s.cur.x] = true
+	case 'M': // RI
+		s.reverseIndex()
+	case 'c': // RIS
+		s.reset()
+	case '=': // DECKPAM
+		s.modes |= ModeAppKeypad
+	case '>': // DECKPNM
+		s.modes &^= ModeAppKeypad
+	case 'n': // LS2
+		s.gl = 2
+	case 'o': // LS3
+		s.gl = 3
+	}
+}
+
+// csiDispatch handles a control sequence CSI [private] params [intermediates] final
+func (s *Screen) csiDispatch(final byte) {
+	p := &s.parser
+	params := p.params()
+
+	if len(p.intermediate) > 0 {
+		s.csiIntermediateDispatch(final, params)
+		return
+	}
+
+	switch p.private {
+	case '?':
+		switch final {
+		case 'h':
+			s.setPrivateModes(params, true)
+		case 'l':
+			s.setPrivateModes(params, false)
+		case 'n':
+			if param(params, 0, 0) == 6 { // DECXCPR
+				s.reply(fmt.Sprintf("\x1b[?%d;%dR", s.reportedRow(), s.cur.x+1))
+			}
+		case 'J':
+			s.eraseInDisplay(param(params, 0, 0))
+		case 'K':
+			s.eraseInLine(param(params, 0, 0))
+		}
+		return
+	case '>':
+		if final == 'c' { // secondary DA
+			s.reply("\x1b[>0;10;1c")
+		}
+		return
+	case 0:
+	default:
+		return
+	}
+
+	switch final {
+	case '@': // ICH
+		s.insertBlanks(param(params, 0, 1))
+	case 'A': // CUU
+		s.moveRelative(0, -param(params, 0, 1))
+	case 'B', 'e': // CUD, VPR
+		s.moveRelative(0, param(params, 0, 1))
+	case 'C', 'a': // CUF, HPR
+		s.moveRelative(param(params, 0, 1), 0)
+	case 'D': // CUB
+		s.moveRelative(-param(params, 0, 1), 0)
+	case 'E': // CNL
+		s.moveRelative(0, param(params, 0, 1))
+		s.cur.x = 0
+	case 'F': // CPL
+		s.moveRelative(0, -param(params, 0, 1))
+		s.cur.x = 0
+	case 'G', '`': // CHA, HPA
+		s.cur.x = min(param(params, 0, 1)-1, s.width-1)
+		s.cur.wrapNext = false
+	case 'H', 'f': // CUP, HVP
+		s.moveTo(param(params, 1, 1)-1, param(params, 0, 1)-1)
+	case 'I': // CHT
+		s.tabForward(param(params, 0, 1))
+	case 'J': // ED
+		s.eraseInDisplay(param(params, 0, 0))
+	case 'K': // EL
+		s.eraseInLine(param(params, 0, 0))
+	case 'L': // IL
+		s.insertLines(param(params, 0, 1))
+	case 'M': // DL
+		s.deleteLines(param(params, 0, 1))
+	case 'P': // DCH
+		s.deleteChars(param(params, 0, 1))
+	case 'S': // SU
+		s.scrollUp(param(params, 0, 1))
+	case 'T': // SD
+		s.scrollDown(param(params, 0, 1))
+	case 'X': // ECH
+		s.eraseCells(s.cur.y, s.cur.x, s.cur.x+param(params, 0, 1))
+		s.cur.wrapNext = false
+	case 'Z': // CBT
+		s.tabBackward(param(params, 0, 1))
+	case 'b': // REP
+		if s.lastRune != 0 {
+			for n := min(param(params, 0, 1), s.width*s.height); n > 0; n-- {
+				s.print(s.lastRune)
+			}
+		}
+	case 'c': // primary DA: VT100 with advanced video option
+		if param(params, 0, 0) == 0 {
+			s.reply("\x1b[?1;2c")
+		}
+	case 'd': // VPA
+		row := param(params, 0, 1) - 1
+		if s.modes&ModeOrigin != 0 {
+			s.moveTo(s.cur.x, row)
+		} else {
+			s.cur.y = min(row, s.height-1)
+			s.cur.wrapNext = false
+		}
+	case 'g': // TBC
+		switch param(params, 0, 0) {
+		case 0:
+			s.tabs[s.cur.x] = false
+		case 3:
+			clear(s.tabs)
+		}
+	case 'h':
+		s.setANSIModes(params, true)
+	case 'l':
+		s.setANSIModes(params, false)
+	case 'm':
+		s.selectGraphicRendition(params)
+	case 'n': // DSR
+		switch param(params, 0, 0) {
+		case 5:
+			s.reply("\x1b[0n")
+		case 6:
+			s.reply(fmt.Sprintf("\x1b[%d;%dR", s.reportedRow(), s.cur.x+1))
+		}
+	case 'r': // DECSTBM
+		top := param(params, 0, 1) - 1
+		bottom := param(params, 1, s.height) - 1
+		bottom = min(bottom, s.height-1)
+		if top < bottom {
+			s.top, s.bottom = top, bottom
+			s.moveTo(0, 0)
+		}
+	case 's': // SCOSC
+		s.saveCursor()
+	case 'u': // SCORC
+		s.restoreCursor()
+	case 't': // window manipulation
+		if param(params, 0, 0) == 18 {
+			s.reply(fmt.Sprintf("\x1b[8;%d;%dt", s.height, s.width))
+		}
+	}
+}
+
+// csiIntermediateDispatch handles control sequences with intermediate bytes
+func (s *Screen) csiIntermediateDispatch(final byte, params [][]int) {
+	p := &s.parser
+	switch string(p.intermediate) + string(final) {
+	case "!p": // DECSTR
+		s.softReset()
+	case "$p": // DECRQM
+		mode := param(params, 0, 0)
+		private := p.private == '?'
+		state := 0 // not recognized
+		if m, ok := lookupMode(mode, private); ok {
+			state = 2
+			if s.modes&m != 0 {
+				state = 1
+			}
+		}
+		if private {
+			s.reply(fmt.Sprintf("\x1b[?%d;%d$y", mode, state))
+		} else {
+			s.reply(fmt.Sprintf("\x1b[%d;%d$y", mode, state))
+		}
+	}
+}
+
+// reportedRow returns the 1-based cursor row as reported by CPR
+func (s *Screen) reportedRow() int {
+	if s.modes&ModeOrigin != 0 {
+		return s.cur.y - s.top + 1
+	}
+	return s.cur.y + 1
+}
+
+// softReset implements DECSTR
+func (s *Screen) softReset() {
+	s.modes &^= ModeInsert | ModeOrigin | ModeAppCursor | ModeAppKeypad
+	s.modes |= ModeAutoWrap | ModeShowCursor
+	s.top, s.bottom = 0, s.height-1
+	s.cur.style = Style{}
+	s.charsets = [4]charset{}
+	s.gl = 0
+	s.saved = [2]savedCursor{}
+}
+
+// screenAlignment implements DECALN, filling the screen with 'E'
+func (s *Screen) screenAlignment() {
+	for y := range s.lines {
+		for x := range s.lines[y].Cells {
+			s.lines[y].Cells[x] = Cell{Rune: 'E', Width: 1}
+		}
+		s.lines[y].Wrapped = false
+	}
+	s.top, s.bottom = 0, s.height-1
+	s.moveTo(0, 0)
+}
+
+// ansiModes maps SM/RM parameters to modes
+var ansiModes = map[int]Mode{
+	4:  ModeInsert,
+	20: ModeLineFeed,
+}
+
+// privateModes maps DECSET/DECRST parameters to modes. Modes with side
+// effects (alternate screen, origin) are handled in setPrivateModes.
+var privateModes = map[int]Mode{
+	1:    ModeAppCursor,
+	5:    ModeReverseVideo,
+	6:    ModeOrigin,
+	7:    ModeAutoWrap,
+	9:    ModeMouseX10,
+	25:   ModeShowCursor,
+	47:   ModeAltScreen,
+	66:   ModeAppKeypad,
+	1000: ModeMouseNormal,
+	1002: ModeMouseButton,
+	1003: ModeMouseAny,
+	1004: ModeFocus,
+	1006: ModeMouseSGR,
+	1047: ModeAltScreen,
+	1049: ModeAltScreen,
+	2004: ModeBracketedPaste,
+}
+
+// lookupMode resolves a mode number for DECRQM
+func lookupMode(n int, private bool) (Mode, bool) {
+	if private {
+		m, ok := privateModes[n]
+		return m, ok
+	}
+	m, ok := ansiModes[n]
+	return m, ok
+}
+
+// setANSIModes implements SM and RM
+func (s *Screen) setANSIModes(params [][]int, on bool) {
+	for _, group := range params {
+		if m, ok := ansiModes[group[0]]; ok {
+			s.setMode(m, on)
+		}
+	}
+}
+
+// setPrivateModes implements DECSET and DECRST
+func (s *Screen) setPrivateModes(params [][]int, on bool) {
+	for _, group := range params {
+		switch n := group[0]; n {
+		case 6: // DECOM
+			s.setMode(ModeOrigin, on)
+			s.moveTo(0, 0)
+		case 47:
+			s.switchBuffer(on, false)
+		case 1047:
+			s.switchBuffer(on, !on)
+		case 1048:
+			if on {
+				s.saveCursor()
+			} else {
+				s.restoreCursor()
+			}
+		case 1049:
+			if on {
+				s.saveCursor()
+				s.switchBuffer(true, false)
+				for y := range s.lines {
+					s.lines[y] = newLine(s.width, Style{})
+				}
+			} else {
+				s.switchBuffer(false, false)
+				s.restoreCursor()
+			}
+		case 9, 1000, 1002, 1003:
+			// mouse tracking modes are mutually exclusive
+			s.modes &^= ModeMouseX10 | ModeMouseNormal | ModeMouseButton | ModeMouseAny
+			s.setMode(privateModes[n], on)
+		default:
+			if m, ok := privateModes[n]; ok {
+				s.setMode(m, on)
+			}
+		}
+	}
+}
+
+// dcsDispatch handles a device control string. data starts with the final
+// byte of the introducer.
+func (s *Screen) dcsDispatch(data []byte) {
+	if len(data) == 0 {
+		return
+	}
+	switch string(s.parser.intermediate) + string(data[0]) {
+	case "$q": // DECRQSS: report every request as invalid
+		s.reply("\x1bP0$r\x1b\\")
+	case "+q": // XTGETTCAP: no capabilities are reported
+		s.reply("\x1bP0+r\x1b\\")
+	}
+}
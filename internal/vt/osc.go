@@ -0,0 +1,173 @@
+package vt
+
+import (
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// oscDispatch handles an operating system command, "Ps ; Pt". Commands
+// that are not interpreted are consumed so they do not reach the screen.
+func (s *Screen) oscDispatch(data []byte) {
+	cmd, arg, _ := strings.Cut(string(data), ";")
+	switch cmd {
+	case "0":
+		s.title = sanitizeTitle(arg)
+		s.iconName = s.title
+	case "1":
+		s.iconName = sanitizeTitle(arg)
+	case "2":
+		s.title = sanitizeTitle(arg)
+	case "7":
+		s.workingDir = sanitizeTitle(arg)
+	case "133":
+		s.semanticPrompt(arg)
+	}
+}
+
+// CommandEvent reports a shell command starting or finishing, from the
+// OSC 133 marks of a shell integration
+type CommandEvent struct {
+	Finished bool   // false when the command starts, true when it finishes
+	Command  string // the command line, when it starts
+	ExitCode int    // the exit status when it finishes, -1 if not reported
+}
+
+// commandStart is the position where the command line begins, kept as
+// an absolute row so it survives scrolling
+type commandStart struct {
+	row   uint64 // scrollCount-relative row
+	x     int
+	valid bool
+}
+
+// SetCommandFunc sets the function told about commands run at a shell
+// prompt. It is called outside the screen lock.
+func (s *Screen) SetCommandFunc(fn func(CommandEvent)) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.commandFn = fn
+}
+
+// semanticPrompt handles the FinalTerm marks: "A" where the prompt starts,
+// "B" where the command line starts, "C" when the command runs and
+// "D;status" when it finishes
+func (s *Screen) semanticPrompt(arg string) {
+	if s.modes&ModeAltScreen != 0 {
+		return
+	}
+	mark, params, _ := strings.Cut(arg, ";")
+	switch mark {
+	case "A":
+		s.lines[s.cur.y].Prompt = true
+	case "B":
+		s.cmdStart = commandStart{row: s.scrollCount + uint64(s.cur.y), x: s.cur.x, valid: true}
+	case "C":
+		if s.cmdStart.valid {
+			s.events = append(s.events, CommandEvent{Command: s.commandLine()})
+			s.cmdStart.valid = false
+		}
+	case "D":
+		code := -1
+		status, _, _ := strings.Cut(params, ";")
+		if n, err := strconv.Atoi(status); err == nil {
+			code = n
+		}
+		s.events = append(s.events, CommandEvent{Finished: true, ExitCode: code})
+	}
+}
+
+// commandLine returns the text from the command start to the cursor, with
+// rows joined by spaces
+func (s *Screen) commandLine() string {
+	first := s.scrollCount - uint64(s.scrollbackLen())
+	if s.cmdStart.row < first {
+		// Scrolled out of the scrollback
+		return ""
+	}
+	start := int(s.cmdStart.row - first)
+	end := s.scrollbackLen() + s.cur.y
+
+	var sb strings.Builder
+	for i := start; i <= end && i < s.scrollbackLen()+len(s.lines); i++ {
+		l := s.row(i)
+		from, to := 0, len(l.Cells)
+		if i == start {
+			from = min(s.cmdStart.x, to)
+		}
+		if i == end {
+			to = min(s.cur.x, to)
+		}
+		if to > from {
+			sb.WriteString(Line{Cells: l.Cells[from:to]}.String())
+		}
+		if !l.Wrapped {
+			sb.WriteByte(' ')
+		}
+	}
+	return strings.Join(strings.Fields(sb.String()), " ")
+}
+
+// PromptOffset returns the scroll offset that puts the previous (dir < 0)
+// or next (dir > 0) prompt line, relative to the view at offset, at the
+// top of the view. Without such a prompt, ok is false; scrolling forward
+// past the last prompt returns to the live screen.
+func (s *Screen) PromptOffset(offset, dir int) (newOffset int, ok bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.modes&ModeAltScreen != 0 {
+		return 0, false
+	}
+	n := s.scrollbackLen()
+	offset = min(max(offset, 0), n)
+	top := n - offset
+
+	if dir < 0 {
+		for i := top - 1; i >= 0; i-- {
+			if s.row(i).Prompt {
+				return n - i, true
+			}
+		}
+		return offset, false
+	}
+	if offset == 0 {
+		return 0, false
+	}
+	for i := top + 1; i < n; i++ {
+		if s.row(i).Prompt {
+			return n - i, true
+		}
+	}
+	return 0, true
+}
+
+// sanitizeTitle drops control characters and invalid UTF-8 from a title
+func sanitizeTitle(s string) string {
+	s = strings.ToValidUTF8(s, "")
+	return strings.Map(func(r rune) rune {
+		if unicode.IsControl(r) {
+			return -1
+		}
+		return r
+	}, s)
+}
+
+// Title returns the window title set by the program, falling back to the
+// icon name. It is empty until the program sets one.
+func (s *Screen) Title() string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.title != "" {
+		return s.title
+	}
+	return s.iconName
+}
+
+// WorkingDir returns the URL of the working directory last reported
+// through OSC 7, such as "file://host/home/me", or ""
+func (s *Screen) WorkingDir() string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.workingDir
+}
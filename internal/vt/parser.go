@@ -0,0 +1,349 @@
+package vt
+
+import "unicode/utf8"
+
+// parserState is a state of the escape sequence parser. The states follow
+// the DEC ANSI parser described at https://vt100.net/emu/dec_ansi_parser
+type parserState uint8
+
+const (
+	stateGround parserState = iota
+	stateEscape
+	stateEscapeIntermediate
+	stateCSIEntry
+	stateCSIParam
+	stateCSIIntermediate
+	stateCSIIgnore
+	stateOSCString
+	stateDCSEntry
+	stateDCSParam
+	stateDCSIntermediate
+	stateDCSPassthrough
+	stateDCSIgnore
+	stateSOSPMAPCString
+)
+
+const (
+	maxParams    = 32
+	maxParamVal  = 65535
+	maxOSCLength = 4096
+)
+
+// parser holds the state of a partially received escape sequence
+type parser struct {
+	state        parserState
+	private      byte   // private marker of a CSI/DCS sequence ('?', '>', '=', '<')
+	intermediate []byte // intermediate bytes (0x20-0x2f)
+	vals         []int  // parameter values, -1 for an omitted value
+	sub          []bool // sub[i] marks vals[i] as a colon separated subparameter
+	cur          int    // value being accumulated, -1 when empty
+	nextSub      bool   // the value being accumulated follows a colon
+	str          []byte // OSC / DCS payload
+	strEsc       bool   // an ESC was seen inside a string, expecting ST
+	utf8         [utf8.UTFMax]byte
+	utf8Len      int
+}
+
+// reset clears the sequence collected so far
+func (p *parser) reset() {
+	p.private = 0
+	p.intermediate = p.intermediate[:0]
+	p.vals = p.vals[:0]
+	p.sub = p.sub[:0]
+	p.cur = -1
+	p.nextSub = false
+}
+
+// digit accumulates a parameter digit
+func (p *parser) digit(b byte) {
+	if p.cur < 0 {
+		p.cur = 0
+	}
+	if p.cur < maxParamVal {
+		p.cur = p.cur*10 + int(b-'0')
+	}
+}
+
+// separator finishes the current parameter; colon starts a subparameter
+func (p *parser) separator(colon bool) {
+	if len(p.vals) < maxParams {
+		p.vals = append(p.vals, p.cur)
+		p.sub = append(p.sub, p.nextSub)
+	}
+	p.cur = -1
+	p.nextSub = colon
+}
+
+// params finishes parameter collection and groups subparameters with the
+// parameter they belong to
+func (p *parser) params() [][]int {
+	if p.cur >= 0 || len(p.vals) > 0 {
+		p.separator(false)
+	}
+	var groups [][]int
+	for i, v := range p.vals {
+		if p.sub[i] && len(groups) > 0 {
+			groups[len(groups)-1] = append(groups[len(groups)-1], v)
+			continue
+		}
+		groups = append(groups, []int{v})
+	}
+	return groups
+}
+
+// Write feeds output of the program running in the terminal to the
+// emulator. It never fails.
+func (s *Screen) Write(data []byte) (int, error) {
+	s.mu.Lock()
+	for _, b := range data {
+		s.advance(b)
+	}
+	replies := s.replies
+	s.replies = nil
+	replyFn := s.replyFn
+	events := s.events
+	s.events = nil
+	commandFn := s.commandFn
+	s.mu.Unlock()
+
+	if len(replies) > 0 && replyFn != nil {
+		replyFn(replies)
+	}
+	if commandFn != nil {
+		for _, ev := range events {
+			commandFn(ev)
+		}
+	}
+	return len(data), nil
+}
+
+// advance runs one byte through the parser
+func (s *Screen) advance(b byte) {
+	p := &s.parser
+
+	// String states swallow everything up to the terminator
+	switch p.state {
+	case stateOSCString, stateDCSPassthrough, stateDCSIgnore, stateSOSPMAPCString:
+		s.advanceString(b)
+		return
+	}
+
+	// Transitions valid from any state
+	switch b {
+	case 0x18, 0x1a: // CAN, SUB
+		p.state = stateGround
+		p.utf8Len = 0
+		return
+	case 0x1b:
+		p.utf8Len = 0
+		p.reset()
+		p.state = stateEscape
+		return
+	}
+
+	if p.state == stateGround {
+		s.ground(b)
+		return
+	}
+
+	if b < 0x20 {
+		s.execute(b)
+		return
+	}
+	if b == 0x7f {
+		return
+	}
+
+	switch p.state {
+	case stateEscape:
+		switch {
+		case b >= 0x20 && b <= 0x2f:
+			p.intermediate = append(p.intermediate, b)
+			p.state = stateEscapeIntermediate
+		case b == '[':
+			p.reset()
+			p.state = stateCSIEntry
+		case b == ']':
+			p.str = p.str[:0]
+			p.strEsc = false
+			p.state = stateOSCString
+		case b == 'P':
+			p.reset()
+			p.state = stateDCSEntry
+		case b == 'X' || b == '^' || b == '_':
+			p.strEsc = false
+			p.state = stateSOSPMAPCString
+		default:
+			s.escDispatch(b)
+			p.state = stateGround
+		}
+
+	case stateEscapeIntermediate:
+		if b <= 0x2f {
+			p.intermediate = append(p.intermediate, b)
+			return
+		}
+		s.escDispatch(b)
+		p.state = stateGround
+
+	case stateCSIEntry, stateCSIParam:
+		switch {
+		case b >= '0' && b <= '9':
+			p.digit(b)
+			p.state = stateCSIParam
+		case b == ';' || b == ':':
+			p.separator(b == ':')
+			p.state = stateCSIParam
+		case b >= '<' && b <= '?':
+			if p.state == stateCSIEntry {
+				p.private = b
+				p.state = stateCSIParam
+			} else {
+				p.state = stateCSIIgnore
+			}
+		case b <= 0x2f:
+			p.intermediate = append(p.intermediate, b)
+			p.state = stateCSIIntermediate
+		default:
+			s.csiDispatch(b)
+			p.state = stateGround
+		}
+
+	case stateCSIIntermediate:
+		switch {
+		case b <= 0x2f:
+			p.intermediate = append(p.intermediate, b)
+		case b <= 0x3f:
+			p.state = stateCSIIgnore
+		default:
+			s.csiDispatch(b)
+			p.state = stateGround
+		}
+
+	case stateCSIIgnore:
+		if b >= 0x40 {
+			p.state = stateGround
+		}
+
+	case stateDCSEntry, stateDCSParam:
+		switch {
+		case b >= '0' && b <= '9':
+			p.digit(b)
+			p.state = stateDCSParam
+		case b == ';' || b == ':':
+			p.separator(b == ':')
+			p.state = stateDCSParam
+		case b >= '<' && b <= '?':
+			if p.state == stateDCSEntry {
+				p.private = b
+				p.state = stateDCSParam
+			} else {
+				p.state = stateDCSIgnore
+			}
+		case b <= 0x2f:
+			p.intermediate = append(p.intermediate, b)
+			p.state = stateDCSIntermediate
+		default:
+			p.str = append(p.str[:0], b)
+			p.strEsc = false
+			p.state = stateDCSPassthrough
+		}
+
+	case stateDCSIntermediate:
+		switch {
+		case b <= 0x2f:
+			p.intermediate = append(p.intermediate, b)
+		case b <= 0x3f:
+			p.state = stateDCSIgnore
+		default:
+			p.str = append(p.str[:0], b)
+			p.strEsc = false
+			p.state = stateDCSPassthrough
+		}
+	}
+}
+
+// ground handles printable text and C0 controls
+func (s *Screen) ground(b byte) {
+	p := &s.parser
+
+	if p.utf8Len > 0 {
+		if b&0xc0 == 0x80 {
+			p.utf8[p.utf8Len] = b
+			p.utf8Len++
+			if utf8.FullRune(p.utf8[:p.utf8Len]) {
+				r, _ := utf8.DecodeRune(p.utf8[:p.utf8Len])
+				p.utf8Len = 0
+				s.print(r)
+			}
+			return
+		}
+		// truncated sequence: replace it and reprocess this byte
+		p.utf8Len = 0
+		s.print(utf8.RuneError)
+	}
+
+	switch {
+	case b < 0x20:
+		s.execute(b)
+	case b < 0x7f:
+		s.print(rune(b))
+	case b == 0x7f:
+	case b >= 0xc2 && b <= 0xf4:
+		p.utf8[0] = b
+		p.utf8Len = 1
+	default:
+		s.print(utf8.RuneError)
+	}
+}
+
+// advanceString collects OSC, DCS and SOS/PM/APC payloads until BEL or ST
+func (s *Screen) advanceString(b byte) {
+	p := &s.parser
+
+	if p.strEsc {
+		p.strEsc = false
+		s.finishString()
+		if b == '\\' {
+			p.state = stateGround
+			return
+		}
+		// any other sequence aborts the string and starts over
+		p.reset()
+		p.state = stateEscape
+		s.advance(b)
+		return
+	}
+
+	switch b {
+	case 0x1b:
+		p.strEsc = true
+		return
+	case 0x07:
+		if p.state == stateOSCString {
+			s.finishString()
+			p.state = stateGround
+			return
+		}
+	case 0x18, 0x1a:
+		p.state = stateGround
+		return
+	}
+
+	switch p.state {
+	case stateOSCString, stateDCSPassthrough:
+		if len(p.str) < maxOSCLength {
+			p.str = append(p.str, b)
+		}
+	}
+}
+
+// finishString dispatches a completed string sequence
+func (s *Screen) finishString() {
+	switch s.parser.state {
+	case stateOSCString:
+		s.oscDispatch(s.parser.str)
+	case stateDCSPassthrough:
+		s.dcsDispatch(s.parser.str)
+	}
+}
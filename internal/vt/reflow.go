@@ -0,0 +1,137 @@
+package vt
+
+// reflow rewraps the scrollback and the primary buffer to a new size.
+// Soft-wrapped rows are joined back into logical lines and split again at
+// the new width, and cur is moved so it stays on the same character.
+func (s *Screen) reflow(width, height int, cur *cursor) {
+	rows := make([]Line, 0, s.scrollback.Len()+len(s.primary))
+	rows = s.scrollback.appendTo(rows, 0)
+	rows = append(rows, s.primary...)
+	cursorRow := s.scrollback.Len() + cur.y
+
+	// Blank rows below the cursor carry no content
+	end := len(rows)
+	for end > cursorRow+1 && rows[end-1].Len() == 0 {
+		end--
+	}
+	rows = rows[:end]
+
+	out := make([]Line, 0, len(rows))
+	newRow, newX := 0, 0
+	for i := 0; i < len(rows); {
+		// Gather one logical line
+		var cells []Cell
+		cursorPos := -1
+		prompt := rows[i].Prompt
+		for {
+			l := rows[i]
+			if i == cursorRow {
+				cursorPos = len(cells) + cur.x
+			}
+			i++
+			if l.Wrapped && i < len(rows) {
+				cells = append(cells, l.Cells...)
+				continue
+			}
+			cells = append(cells, l.Cells[:l.Len()]...)
+			break
+		}
+
+		wrapped, posRow, posX := rewrap(cells, width, cursorPos)
+		wrapped[0].Prompt = prompt
+		if cursorPos >= 0 {
+			newRow, newX = len(out)+posRow, posX
+		}
+		out = append(out, wrapped...)
+	}
+
+	start := max(len(out)-height, 0)
+	if newRow < start {
+		// More rows below the cursor than fit: keep the cursor row on top
+		start = newRow
+		out = out[:start+height]
+	}
+
+	// Keep line numbers counting up to the screen: the rows now in the
+	// scrollback take the numbers before it, including those that no
+	// longer fit and were dropped or spilled
+	before := s.scrollback.Len()
+	dropped := s.scrollback.set(out[:start])
+	s.spillLines(dropped)
+	s.scrollCount = s.scrollCount - uint64(before) + uint64(s.scrollback.Len()) + uint64(len(dropped))
+	s.primary = out[start:]
+	for len(s.primary) < height {
+		s.primary = append(s.primary, newLine(width, Style{}))
+	}
+	cur.y, cur.x = newRow-start, newX
+	cur.wrapNext = false
+}
+
+// rewrap splits the cells of a logical line into rows of width columns,
+// never splitting a wide character. It returns the rows and the position
+// of the cell at index pos (a negative pos is ignored).
+func rewrap(cells []Cell, width, pos int) (rows []Line, posRow, posX int) {
+	row := make([]Cell, 0, width)
+	flush := func(wrapped bool) {
+		rows = append(rows, resizeLine(Line{Cells: row, Wrapped: wrapped}, width))
+		row = make([]Cell, 0, width)
+	}
+
+	found := pos < 0
+	for i := 0; i < len(cells); i++ {
+		c := cells[i]
+		if c.Width == 0 {
+			// right halves are re-added with their wide character
+			if !found && i == pos {
+				found = true
+				posRow, posX = len(rows), max(len(row)-1, 0)
+			}
+			continue
+		}
+		w := int(c.Width)
+		if w > width {
+			c, w = blankCell(c.Style), 1
+		}
+		if len(row)+w > width {
+			flush(true)
+		}
+		if !found && i == pos {
+			found = true
+			posRow, posX = len(rows), len(row)
+		}
+		row = append(row, c)
+		if w == 2 {
+			row = append(row, Cell{Width: 0, Style: c.Style})
+		}
+	}
+
+	if !found {
+		// the cursor is past the end of the text
+		posRow, posX = len(rows), min(len(row)+pos-len(cells), width-1)
+		if len(row) == width {
+			flush(true)
+			posRow, posX = len(rows), min(pos-len(cells), width-1)
+		}
+	}
+	flush(false)
+	return rows, posRow, posX
+}
+
+// resizeAlternate truncates or pads the alternate buffer, keeping the
+// cursor row on screen
+func resizeAlternate(lines []Line, width, height int, cur *cursor) []Line {
+	for i := range lines {
+		lines[i] = resizeLine(lines[i], width)
+	}
+	if len(lines) > height {
+		drop := max(cur.y-(height-1), 0)
+		lines = lines[drop : drop+height]
+		cur.y -= drop
+	}
+	for len(lines) < height {
+		lines = append(lines, newLine(width, Style{}))
+	}
+	cur.x = min(cur.x, width-1)
+	cur.y = min(cur.y, height-1)
+	return lines
+}
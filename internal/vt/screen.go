@@ -0,0 +1,773 @@
+package vt
+
+import (
+	"strings"
+	"sync"
+
+	"github.com/mattn/go-runewidth"
+)
+
+// Mode is a set of terminal modes
+type Mode uint32
+
+const (
+	ModeInsert         Mode = 1 << iota // IRM: printing shifts the rest of the line right
+	ModeLineFeed                        // LNM: line feed also returns the carriage
+	ModeAppCursor                       // DECCKM: cursor keys send SS3 sequences
+	ModeAppKeypad                       // DECKPAM/DECNKM: keypad sends application sequences
+	ModeReverseVideo                    // DECSCNM: whole screen in reverse video
+	ModeOrigin                          // DECOM: cursor addressing relative to the scroll region
+	ModeAutoWrap                        // DECAWM: printing past the margin wraps
+	ModeShowCursor                      // DECTCEM: cursor visible
+	ModeAltScreen                       // alternate screen buffer active
+	ModeMouseX10                        // mouse press reporting
+	ModeMouseNormal                     // mouse press and release reporting
+	ModeMouseButton                     // mouse motion reporting while a button is held
+	ModeMouseAny                        // all mouse motion reporting
+	ModeMouseSGR                        // SGR encoding of mouse reports
+	ModeFocus                           // focus in/out reporting
+	ModeBracketedPaste                  // pasted text is bracketed
+)
+
+// DefaultScrollback is the number of lines kept above the screen by default
+const DefaultScrollback = 1000
+
+// cursor is the cursor position and the pen used for printing
+type cursor struct {
+	x, y     int
+	style    Style
+	wrapNext bool // the next printed character wraps to a new line first
+}
+
+// savedCursor is the state stored by DECSC
+type savedCursor struct {
+	cursor
+	origin   bool
+	charsets [4]charset
+	gl       int
+}
+
+// Screen is a VT100/xterm compatible terminal emulator. Output of the
+// program is fed through Write and the resulting cell grid is read back
+// through Viewport. A Screen is safe for concurrent use.
+type Screen struct {
+	mu     sync.Mutex
+	parser parser
+
+	width  int
+	height int
+
+	lines     []Line // active buffer, either primary or alternate
+	primary   []Line
+	alternate []Line
+
+	cur      cursor
+	saved    [2]savedCursor // for the primary and the alternate buffer
+	top      int            // scroll region, inclusive
+	bottom   int
+	tabs     []bool
+	modes    Mode
+	charsets [4]charset // G0-G3
+	gl       int        // charset invoked into GL
+	lastRune rune       // last printed character, for REP
+
+	scrollback  scrollback
+	spill       *spill // lines dropped from the scrollback, if kept on disk
+	scrollCount uint64 // lines ever pushed into the scrollback
+
+	title      string // window title, set by OSC 0 and 2
+	iconName   string // icon name, set by OSC 0 and 1
+	workingDir string // working directory URL, set by OSC 7
+
+	cmdStart  commandStart   // where the command line being typed starts
+	events    []CommandEvent // shell integration events not yet delivered
+	commandFn func(CommandEvent)
+
+	replies []byte
+	replyFn func([]byte)
+}
+
+// NewScreen creates a screen of the given size
+func NewScreen(width, height int) *Screen {
+	width, height = max(width, 1), max(height, 1)
+	s := &Screen{
+		width:      width,
+		height:     height,
+		scrollback: scrollback{limit: DefaultScrollback},
+	}
+	s.primary = s.blankLines(height)
+	s.alternate = s.blankLines(height)
+	s.lines = s.primary
+	s.parser.reset()
+	s.resetState()
+	return s
+}
+
+// resetState puts modes, margins, tab stops and the cursor in their
+// power-on state
+func (s *Screen) resetState() {
+	s.modes = ModeAutoWrap | ModeShowCursor
+	s.top, s.bottom = 0, s.height-1
+	s.cur = cursor{}
+	s.charsets = [4]charset{}
+	s.gl = 0
+	s.saved = [2]savedCursor{}
+	s.tabs = make([]bool, s.width)
+	for i := 8; i < s.width; i += 8 {
+		s.tabs[i] = true
+	}
+}
+
+// SetReplyFunc sets the function receiving the terminal's answers to
+// queries (device attributes, cursor position reports). It is called
+// outside the screen lock and should write to the program's input.
+func (s *Screen) SetReplyFunc(fn func([]byte)) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.replyFn = fn
+}
+
+// Size returns the screen dimensions
+func (s *Screen) Size() (width, height int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.width, s.height
+}
+
+// Cursor returns the cursor position and whether it is visible
+func (s *Screen) Cursor() (x, y int, visible bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.cur.x, s.cur.y, s.modes&ModeShowCursor != 0
+}
+
+// Mode reports whether all of the given modes are set
+func (s *Screen) Mode(m Mode) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.modes&m == m
+}
+
+// SetScrollbackLimit sets the maximum number of scrollback lines
+func (s *Screen) SetScrollbackLimit(n int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.spillLines(s.scrollback.setLimit(n))
+}
+
+// SpillScrollback keeps the lines dropped from a full scrollback in a
+// compressed temporary file, where they stay part of the scrollback,
+// instead of discarding them. CloseSpill releases the file.
+func (s *Screen) SpillScrollback() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.spill != nil {
+		return nil
+	}
+	sp, err := newSpill()
+	if err != nil {
+		return err
+	}
+	s.spill = sp
+	return nil
+}
+
+// CloseSpill drops the file of SpillScrollback and the lines in it
+func (s *Screen) CloseSpill() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.spill == nil {
+		return nil
+	}
+	err := s.spill.close()
+	s.spill = nil
+	return err
+}
+
+// spillLines hands lines dropped from the scrollback to the spill file
+func (s *Screen) spillLines(lines []Line) {
+	if s.spill == nil {
+		return
+	}
+	for _, l := range lines {
+		s.spill.push(l)
+	}
+}
+
+// ScrollbackLimit returns the maximum number of scrollback lines
+func (s *Screen) ScrollbackLimit() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.scrollback.limit
+}
+
+// ScrollbackLen returns the number of lines currently in the scrollback
+func (s *Screen) ScrollbackLen() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.scrollbackLen()
+}
+
+// scrollbackLen returns the number of scrollback lines, in memory and
+// spilled to disk
+func (s *Screen) scrollbackLen() int {
+	if s.spill == nil {
+		return s.scrollback.Len()
+	}
+	return s.spill.Len() + s.scrollback.Len()
+}
+
+// ScrollCount returns the number of lines pushed into the scrollback since
+// the screen was created. It lets views stay anchored while output arrives.
+func (s *Screen) ScrollCount() uint64 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.scrollCount
+}
+
+// Viewport returns the screen as seen when scrolled offset lines back into
+// the scrollback (0 shows the live screen). The lines are copies.
+func (s *Screen) Viewport(offset int) []Line {
+	lines, _ := s.NumberedViewport(offset)
+	return lines
+}
+
+// NumberedViewport is Viewport, also returning the number of the first
+// line. Lines are numbered from the first one ever scrolled into the
+// scrollback, so a line keeps its number as output arrives.
+func (s *Screen) NumberedViewport(offset int) (lines []Line, first uint64) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.modes&ModeAltScreen != 0 {
+		offset = 0
+	}
+	offset = min(max(offset, 0), s.scrollbackLen())
+
+	start := s.scrollbackLen() - offset
+	out := make([]Line, s.height)
+	for i := range out {
+		out[i] = s.row(start + i).clone()
+	}
+	return out, s.scrollCount - uint64(offset)
+}
+
+// row returns the i-th row of the scrollback followed by the screen
+// lines. The line shares cells with the screen.
+func (s *Screen) row(i int) Line {
+	if s.spill != nil {
+		n := s.spill.Len()
+		if i < n {
+			l := s.spill.line(i)
+			if len(l.Cells) > s.width {
+				// resizeLine would change the cached cells
+				l = l.clone()
+			}
+			return resizeLine(l, s.width)
+		}
+		i -= n
+	}
+	if i < s.scrollback.Len() {
+		return *s.scrollback.at(i)
+	}
+	return s.lines[i-s.scrollback.Len()]
+}
+
+// Lines returns copies of the scrollback followed by the screen lines
+func (s *Screen) Lines() []Line {
+	return s.Capture(-1)
+}
+
+// Capture returns copies of the last n scrollback lines (all of them when
+// n is negative) followed by the screen lines
+func (s *Screen) Capture(n int) []Line {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	total := s.scrollbackLen()
+	if n < 0 || n > total {
+		n = total
+	}
+	out := make([]Line, 0, n+len(s.lines))
+	for i := total - n; i < total+len(s.lines); i++ {
+		out = append(out, s.row(i).clone())
+	}
+	return out
+}
+
+// String returns the text of the visible screen, one line per row
+func (s *Screen) String() string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	rows := make([]string, len(s.lines))
+	for i, l := range s.lines {
+		rows[i] = l.String()
+	}
+	return strings.Join(rows, "\n")
+}
+
+// Reset performs a full terminal reset, keeping the scrollback
+func (s *Screen) Reset() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.reset()
+}
+
+func (s *Screen) reset() {
+	s.primary = s.blankLines(s.height)
+	s.alternate = s.blankLines(s.height)
+	s.lines = s.primary
+	s.resetState()
+}
+
+// Resize changes the screen dimensions. The primary buffer and the
+// scrollback are reflowed to the new width; the alternate buffer is
+// truncated or padded since full screen programs redraw it themselves.
+func (s *Screen) Resize(width, height int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	width, height = max(width, 1), max(height, 1)
+	if width == s.width && height == s.height {
+		return
+	}
+
+	// Reflowing moves text to other rows
+	s.cmdStart.valid = false
+
+	primaryCursor, altCursor := &s.cur, &s.saved[1].cursor
+	if s.modes&ModeAltScreen != 0 {
+		primaryCursor, altCursor = &s.saved[0].cursor, &s.cur
+	}
+	s.reflow(width, height, primaryCursor)
+	s.alternate = resizeAlternate(s.alternate, width, height, altCursor)
+	if s.modes&ModeAltScreen != 0 {
+		s.lines = s.alternate
+	} else {
+		s.lines = s.primary
+	}
+
+	tabs := make([]bool, width)
+	copy(tabs, s.tabs)
+	for i := (s.width/8 + 1) * 8; i < width; i += 8 {
+		tabs[i] = true
+	}
+	s.tabs = tabs
+
+	s.width, s.height = width, height
+	s.top, s.bottom = 0, height-1
+	s.cur.wrapNext = false
+	for i := range s.saved {
+		s.saved[i].x = min(s.saved[i].x, width-1)
+		s.saved[i].y = min(s.saved[i].y, height-1)
+	}
+}
+
+// resizeLine truncates or pads a line to width
+func resizeLine(l Line, width int) Line {
+	if len(l.Cells) >= width {
+		l.Cells = l.Cells[:width]
+		if width > 0 && l.Cells[width-1].Width == 2 {
+			l.Cells[width-1] = blankCell(l.Cells[width-1].Style)
+		}
+		return l
+	}
+	blank := blankCell(Style{})
+	for len(l.Cells) < width {
+		l.Cells = append(l.Cells, blank)
+	}
+	return l
+}
+
+// blankLines returns n blank lines of the screen width
+func (s *Screen) blankLines(n int) []Line {
+	lines := make([]Line, n)
+	for i := range lines {
+		lines[i] = newLine(s.width, Style{})
+	}
+	return lines
+}
+
+// pushScrollback appends a line scrolled off the top of the primary
+// screen. It returns a blank line to take its place, reusing the cells of
+// the line dropped from a full scrollback. Without a scrollback, lines go
+// straight to the spill file if there is one.
+func (s *Screen) pushScrollback(l Line) Line {
+	if s.scrollback.limit > 0 || s.spill != nil {
+		s.scrollCount++
+	}
+	dropped, ok := s.scrollback.push(l)
+	if ok {
+		s.spillLines([]Line{dropped})
+	}
+	if !ok || cap(dropped.Cells) < s.width {
+		return newLine(s.width, s.cur.style)
+	}
+	cells := dropped.Cells[:s.width]
+	blank := blankCell(s.cur.style)
+	for i := range cells {
+		cells[i] = blank
+	}
+	return Line{Cells: cells}
+}
+
+// reply queues an answer to the program
+func (s *Screen) reply(b string) {
+	s.replies = append(s.replies, b...)
+}
+
+// print writes a character at the cursor
+func (s *Screen) print(r rune) {
+	r = s.charsets[s.gl].translate(r)
+	w := runewidth.RuneWidth(r)
+	if w == 0 {
+		// combining marks and other zero width characters are not stored
+		return
+	}
+	s.lastRune = r
+
+	autoWrap := s.modes&ModeAutoWrap != 0
+	if s.cur.wrapNext && autoWrap {
+		s.lines[s.cur.y].Wrapped = true
+		s.cur.x = 0
+		s.index()
+	}
+	s.cur.wrapNext = false
+
+	if w == 2 && s.cur.x == s.width-1 {
+		if s.width < 2 {
+			return
+		}
+		if autoWrap {
+			s.setCell(s.cur.x, s.cur.y, blankCell(s.cur.style))
+			s.lines[s.cur.y].Wrapped = true
+			s.cur.x = 0
+			s.index()
+		} else {
+			s.cur.x = s.width - 2
+		}
+	}
+
+	if s.modes&ModeInsert != 0 {
+		s.insertBlanks(w)
+	}
+
+	s.setCell(s.cur.x, s.cur.y, Cell{Rune: r, Width: uint8(w), Style: s.cur.style})
+	if w == 2 {
+		s.setCell(s.cur.x+1, s.cur.y, Cell{Width: 0, Style: s.cur.style})
+	}
+
+	if s.cur.x+w >= s.width {
+		s.cur.x = s.width - 1
+		s.cur.wrapNext = autoWrap
+	} else {
+		s.cur.x += w
+	}
+}
+
+// setCell stores a cell, blanking the other half of any wide character it
+// partially overwrites
+func (s *Screen) setCell(x, y int, c Cell) {
+	cells := s.lines[y].Cells
+	old := cells[x]
+	if old.Width == 0 && x > 0 && c.Width != 0 {
+		cells[x-1] = blankCell(cells[x-1].Style)
+	}
+	if old.Width == 2 && x+1 < len(cells) && c.Width != 2 {
+		cells[x+1] = blankCell(cells[x+1].Style)
+	}
+	cells[x] = c
+}
+
+// execute handles a C0 control character
+func (s *Screen) execute(b byte) {
+	switch b {
+	case 0x07: // BEL
+	case 0x08: // BS
+		if s.cur.x > 0 {
+			s.cur.x--
+		}
+		s.cur.wrapNext = false
+	case 0x09: // HT
+		s.tabForward(1)
+	case 0x0a, 0x0b, 0x0c: // LF, VT, FF
+		s.index()
+		if s.modes&ModeLineFeed != 0 {
+			s.cur.x = 0
+		}
+	case 0x0d: // CR
+		s.cur.x = 0
+		s.cur.wrapNext = false
+	case 0x0e: // SO
+		s.gl = 1
+	case 0x0f: // SI
+		s.gl = 0
+	}
+}
+
+// index moves the cursor down, scrolling at the bottom margin
+func (s *Screen) index() {
+	s.cur.wrapNext = false
+	if s.cur.y == s.bottom {
+		s.scrollUp(1)
+	} else if s.cur.y < s.height-1 {
+		s.cur.y++
+	}
+}
+
+// reverseIndex moves the cursor up, scrolling at the top margin
+func (s *Screen) reverseIndex() {
+	s.cur.wrapNext = false
+	if s.cur.y == s.top {
+		s.scrollDown(1)
+	} else if s.cur.y > 0 {
+		s.cur.y--
+	}
+}
+
+// scrollUp scrolls the scroll region up by n lines. Lines leaving the top
+// of the primary screen go to the scrollback.
+func (s *Screen) scrollUp(n int) {
+	s.removeRows(s.top, n, s.top == 0 && s.modes&ModeAltScreen == 0)
+}
+
+// scrollDown scrolls the scroll region down by n lines
+func (s *Screen) scrollDown(n int) {
+	s.insertRows(s.top, n)
+}
+
+// removeRows deletes n rows starting at row top, pulling up the rest of
+// the scroll region and filling its bottom with blank rows
+func (s *Screen) removeRows(top, n int, save bool) {
+	n = min(n, s.bottom-top+1)
+	for i := 0; i < n; i++ {
+		blank := Line{}
+		if save {
+			blank = s.pushScrollback(s.lines[top])
+		} else {
+			blank = newLine(s.width, s.cur.style)
+		}
+		copy(s.lines[top:s.bottom], s.lines[top+1:s.bottom+1])
+		s.lines[s.bottom] = blank
+	}
+}
+
+// insertRows inserts n blank rows at row top, pushing the rest of the
+// scroll region down
+func (s *Screen) insertRows(top, n int) {
+	n = min(n, s.bottom-top+1)
+	for i := 0; i < n; i++ {
+		copy(s.lines[top+1:s.bottom+1], s.lines[top:s.bottom])
+		s.lines[top] = newLine(s.width, s.cur.style)
+	}
+}
+
+// tabForward moves the cursor to the n-th next tab stop
+func (s *Screen) tabForward(n int) {
+	for ; n > 0 && s.cur.x < s.width-1; n-- {
+		s.cur.x++
+		for s.cur.x < s.width-1 && !s.tabs[s.cur.x] {
+			s.cur.x++
+		}
+	}
+	s.cur.wrapNext = false
+}
+
+// tabBackward moves the cursor to the n-th previous tab stop
+func (s *Screen) tabBackward(n int) {
+	for ; n > 0 && s.cur.x > 0; n-- {
+		s.cur.x--
+		for s.cur.x > 0 && !s.tabs[s.cur.x] {
+			s.cur.x--
+		}
+	}
+	s.cur.wrapNext = false
+}
+
+// moveTo moves the cursor to an absolute position, honoring origin mode
+func (s *Screen) moveTo(x, y int) {
+	minY, maxY := 0, s.height-1
+	if s.modes&ModeOrigin != 0 {
+		y += s.top
+		minY, maxY = s.top, s.bottom
+	}
+	s.cur.x = min(max(x, 0), s.width-1)
+	s.cur.y = min(max(y, minY), maxY)
+	s.cur.wrapNext = false
+}
+
+// moveRelative moves the cursor without leaving the scroll region when it
+// starts inside it
+func (s *Screen) moveRelative(dx, dy int) {
+	minY, maxY := 0, s.height-1
+	if s.cur.y >= s.top && s.cur.y <= s.bottom {
+		minY, maxY = s.top, s.bottom
+	}
+	s.cur.x = min(max(s.cur.x+dx, 0), s.width-1)
+	s.cur.y = min(max(s.cur.y+dy, minY), maxY)
+	s.cur.wrapNext = false
+}
+
+// eraseCells blanks cells [from, to) of row y
+func (s *Screen) eraseCells(y, from, to int) {
+	from, to = max(from, 0), min(to, s.width)
+	for x := from; x < to; x++ {
+		s.setCell(x, y, blankCell(s.cur.style))
+	}
+}
+
+// eraseInLine implements EL
+func (s *Screen) eraseInLine(mode int) {
+	switch mode {
+	case 0:
+		s.eraseCells(s.cur.y, s.cur.x, s.width)
+		s.lines[s.cur.y].Wrapped = false
+	case 1:
+		s.eraseCells(s.cur.y, 0, s.cur.x+1)
+	case 2:
+		s.eraseCells(s.cur.y, 0, s.width)
+		s.lines[s.cur.y].Wrapped = false
+	}
+	s.cur.wrapNext = false
+}
+
+// eraseInDisplay implements ED
+func (s *Screen) eraseInDisplay(mode int) {
+	switch mode {
+	case 0:
+		s.eraseInLine(0)
+		for y := s.cur.y + 1; y < s.height; y++ {
+			s.lines[y] = newLine(s.width, s.cur.style)
+		}
+	case 1:
+		s.eraseInLine(1)
+		for y := 0; y < s.cur.y; y++ {
+			s.lines[y] = newLine(s.width, s.cur.style)
+		}
+	case 2:
+		for y := range s.lines {
+			s.lines[y] = newLine(s.width, s.cur.style)
+		}
+	case 3:
+		s.scrollback.clear()
+		if s.spill != nil {
+			s.spill.clear()
+		}
+	}
+	s.cur.wrapNext = false
+}
+
+// insertBlanks shifts the rest of the cursor row right by n cells
+func (s *Screen) insertBlanks(n int) {
+	cells := s.lines[s.cur.y].Cells
+	n = min(n, s.width-s.cur.x)
+	copy(cells[s.cur.x+n:], cells[s.cur.x:])
+	for x := s.cur.x; x < s.cur.x+n; x++ {
+		cells[x] = blankCell(s.cur.style)
+	}
+	if last := cells[s.width-1]; last.Width == 2 {
+		cells[s.width-1] = blankCell(last.Style)
+	}
+	s.cur.wrapNext = false
+}
+
+// deleteChars removes n cells at the cursor, shifting the rest left
+func (s *Screen) deleteChars(n int) {
+	cells := s.lines[s.cur.y].Cells
+	n = min(n, s.width-s.cur.x)
+	if cells[s.cur.x].Width == 0 && s.cur.x > 0 {
+		cells[s.cur.x-1] = blankCell(cells[s.cur.x-1].Style)
+	}
+	copy(cells[s.cur.x:], cells[s.cur.x+n:])
+	for x := s.width - n; x < s.width; x++ {
+		cells[x] = blankCell(s.cur.style)
+	}
+	if cells[s.cur.x].Width == 0 {
+		cells[s.cur.x] = blankCell(cells[s.cur.x].Style)
+	}
+	s.cur.wrapNext = false
+}
+
+// insertLines inserts n blank lines at the cursor row inside the region
+func (s *Screen) insertLines(n int) {
+	if s.cur.y < s.top || s.cur.y > s.bottom {
+		return
+	}
+	s.insertRows(s.cur.y, n)
+	s.cur.x = 0
+	s.cur.wrapNext = false
+}
+
+// deleteLines removes n lines at the cursor row inside the region
+func (s *Screen) deleteLines(n int) {
+	if s.cur.y < s.top || s.cur.y > s.bottom {
+		return
+	}
+	s.removeRows(s.cur.y, n, false)
+	s.cur.x = 0
+	s.cur.wrapNext = false
+}
+
+// saveCursor implements DECSC
+func (s *Screen) saveCursor() {
+	s.saved[s.bufferIndex()] = savedCursor{
+		cursor:   s.cur,
+		origin:   s.modes&ModeOrigin != 0,
+		charsets: s.charsets,
+		gl:       s.gl,
+	}
+}
+
+// restoreCursor implements DECRC
+func (s *Screen) restoreCursor() {
+	sc := s.saved[s.bufferIndex()]
+	s.cur = sc.cursor
+	s.cur.x = min(s.cur.x, s.width-1)
+	s.cur.y = min(s.cur.y, s.height-1)
+	s.setMode(ModeOrigin, sc.origin)
+	s.charsets = sc.charsets
+	s.gl = sc.gl
+}
+
+// bufferIndex returns 0 for the primary and 1 for the alternate buffer
+func (s *Screen) bufferIndex() int {
+	if s.modes&ModeAltScreen != 0 {
+		return 1
+	}
+	return 0
+}
+
+// switchBuffer activates the alternate (or primary) buffer
+func (s *Screen) switchBuffer(alt, clear bool) {
+	if alt == (s.modes&ModeAltScreen != 0) {
+		return
+	}
+	if alt {
+		s.lines = s.alternate
+		s.modes |= ModeAltScreen
+		if clear {
+			for y := range s.lines {
+				s.lines[y] = newLine(s.width, s.cur.style)
+			}
+		}
+	} else {
+		if clear {
+			for y := range s.alternate {
+				s.alternate[y] = newLine(s.width, s.cur.style)
+			}
+		}
+		s.lines = s.primary
+		s.modes &^= ModeAltScreen
+	}
+	s.cur.wrapNext = false
+}
+
+// setMode sets or clears modes
+func (s *Screen) setMode(m Mode, on bool) {
+	if on {
+		s.modes |= m
+	} else {
+		s.modes &^= m
+	}
+}
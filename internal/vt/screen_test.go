@@ -0,0 +1,253 @@
+package vt_test
+
+import (
+	"slices"
+	"strings"
+	"terbox/internal/vt"
+	"testing"
+)
+
+// pos is a cell of Lines(): a column and an index into the lines
+type pos struct{ x, y int }
+
+// golden is a byte stream fed to a screen and the state it must leave
+type golden struct {
+	name          string
+	width, height int
+	input         []string // each written with its own Write call
+	lines         []string // text of Lines(): scrollback then screen
+	wrapped       []int    // indexes of the lines that are soft-wrapped
+	cursor        pos
+	styles        map[pos]vt.Style
+	title         string
+	replies       string
+}
+
+var goldens = []golden{
+	{
+		name:  "CUP, EL and ED",
+		width: 10, height: 4,
+		input: []string{
+			"hello\r\nworld\r\nfoo",
+			"\x1b[2;3H\x1b[K",  // erase to the end of line 2
+			"\x1b[1;2H\x1b[1K", // erase to the start of line 1, cursor included
+			"\x1b[3;2H\x1b[J",  // erase below from the second column of line 3
+		},
+		lines:  []string{"  llo", "wo", "f", ""},
+		cursor: pos{1, 2},
+	},
+	{
+		name:  "ED 2 keeps the cursor",
+		width: 5, height: 2,
+		input:  []string{"ab\r\ncd", "\x1b[2J"},
+		lines:  []string{"", ""},
+		cursor: pos{2, 1},
+	},
+	{
+		name:  "lines scrolled off go to the scrollback",
+		width: 3, height: 2,
+		input:  []string{"a\r\nb\r\nc"},
+		lines:  []string{"a", "b", "c"},
+		cursor: pos{1, 1},
+	},
+	{
+		name:  "IND at the bottom margin scrolls the region",
+		width: 5, height: 5,
+		input:  []string{"1\r\n2\r\n3\r\n4\r\n5", "\x1b[2;4r", "\x1b[4;1H\x1bD"},
+		lines:  []string{"1", "3", "4", "", "5"},
+		cursor: pos{0, 3},
+	},
+	{
+		name:  "RI at the top margin scrolls the region down",
+		width: 5, height: 5,
+		input:  []string{"1\r\n2\r\n3\r\n4\r\n5", "\x1b[2;4r", "\x1b[2;1H\x1bM"},
+		lines:  []string{"1", "", "2", "3", "5"},
+		cursor: pos{0, 1},
+	},
+	{
+		name:  "tab stops",
+		width: 20, height: 3,
+		input: []string{
+			"a\tb\r\n",
+			"\x1b[5G\x1bH\r\tc\r\n", // HTS at column 5
+			"\x1b[3g\tX",            // TBC 3 clears every stop
+		},
+		lines:  []string{"a       b", "    c", strings.Repeat(" ", 19) + "X"},
+		cursor: pos{19, 2},
+	},
+	{
+		name:  "DECAWM on wraps at the margin",
+		width: 5, height: 3,
+		input:   []string{"abcdefg"},
+		lines:   []string{"abcde", "fg", ""},
+		wrapped: []int{0},
+		cursor:  pos{2, 1},
+	},
+	{
+		name:  "DECAWM off overwrites the last column",
+		width: 5, height: 2,
+		input:  []string{"\x1b[?7l", "hijklmn"},
+		lines:  []string{"hijkn", ""},
+		cursor: pos{4, 0},
+	},
+	{
+		name:  "alternate screen 1049",
+		width: 5, height: 2,
+		input:  []string{"main", "\x1b[?1049h", "\x1b[Halt"},
+		lines:  []string{"alt", ""},
+		cursor: pos{3, 0},
+	},
+	{
+		name:  "leaving 1049 restores the screen and cursor",
+		width: 5, height: 2,
+		input:  []string{"main", "\x1b[?1049h", "\x1b[Halt\r\nmore", "\x1b[?1049l"},
+		lines:  []string{"main", ""},
+		cursor: pos{4, 0},
+	},
+	{
+		name:  "SGR truecolor and 256 colors",
+		width: 10, height: 1,
+		input: []string{
+			"\x1b[38;2;10;20;30;48;5;200mA",
+			"\x1b[1;38:2::1:2:3mB",
+			"\x1b[0mC",
+			"\x1b[31;44mD",
+			"\x1b[38:5:123;49mE",
+		},
+		lines:  []string{"ABCDE"},
+		cursor: pos{5, 0},
+		styles: map[pos]vt.Style{
+			{0, 0}: {Fg: vt.RGBColor(10, 20, 30), Bg: vt.IndexedColor(200)},
+			{1, 0}: {Fg: vt.RGBColor(1, 2, 3), Bg: vt.IndexedColor(200), Attr: vt.AttrBold},
+			{2, 0}: {},
+			{3, 0}: {Fg: vt.IndexedColor(1), Bg: vt.IndexedColor(4)},
+			{4, 0}: {Fg: vt.IndexedColor(123)},
+		},
+	},
+	{
+		name:  "wide character at the right margin wraps",
+		width: 5, height: 2,
+		input:   []string{"abcd世"},
+		lines:   []string{"abcd", "世"},
+		wrapped: []int{0},
+		cursor:  pos{2, 1},
+	},
+	{
+		name:  "wide character at the right margin without wrapping",
+		width: 5, height: 2,
+		input:  []string{"\x1b[?7labcd世"},
+		lines:  []string{"abc世", ""},
+		cursor: pos{4, 0},
+	},
+	{
+		name:  "UTF-8 split across writes",
+		width: 5, height: 1,
+		input:  []string{"\xe4\xb8", "\x96"},
+		lines:  []string{"世"},
+		cursor: pos{2, 0},
+	},
+	{
+		name:  "OSC split across writes, ended by BEL",
+		width: 5, height: 1,
+		input:  []string{"\x1b]0;hel", "lo wor", "ld\x07", "x"},
+		lines:  []string{"x"},
+		cursor: pos{1, 0},
+		title:  "hello world",
+	},
+	{
+		name:  "OSC split inside ST",
+		width: 5, height: 1,
+		input:  []string{"\x1b]2;hel", "lo\x1b", "\\x"},
+		lines:  []string{"x"},
+		cursor: pos{1, 0},
+		title:  "hello",
+	},
+	{
+		name:  "DCS split across writes",
+		width: 5, height: 1,
+		input:   []string{"\x1bP$", "qm\x1b", "\\ok"},
+		lines:   []string{"ok"},
+		cursor:  pos{2, 0},
+		replies: "\x1bP0$r\x1b\\",
+	},
+}
+
+func TestGolden(t *testing.T) {
+	for _, g := range goldens {
+		t.Run(g.name, func(t *testing.T) {
+			s := vt.NewScreen(g.width, g.height)
+			var replies strings.Builder
+			s.SetReplyFunc(func(b []byte) { replies.Write(b) })
+			for _, in := range g.input {
+				if n, err := s.Write([]byte(in)); n != len(in) || err != nil {
+					t.Fatalf("Write(%q) = %d, %v", in, n, err)
+				}
+			}
+
+			lines := s.Lines()
+			text := make([]string, len(lines))
+			var wrapped []int
+			for i, l := range lines {
+				text[i] = l.String()
+				if l.Wrapped {
+					wrapped = append(wrapped, i)
+				}
+			}
+			if !slices.Equal(text, g.lines) {
+				t.Errorf("lines = %q, want %q", text, g.lines)
+			}
+			if !slices.Equal(wrapped, g.wrapped) {
+				t.Errorf("wrapped lines = %v, want %v", wrapped, g.wrapped)
+			}
+			if x, y, _ := s.Cursor(); (pos{x, y}) != g.cursor {
+				t.Errorf("cursor = %v, want %v", pos{x, y}, g.cursor)
+			}
+			for p, want := range g.styles {
+				if got := lines[p.y].Cells[p.x].Style; got != want {
+					t.Errorf("style at %v = %+v, want %+v", p, got, want)
+				}
+			}
+			if title := s.Title(); title != g.title {
+				t.Errorf("title = %q, want %q", title, g.title)
+			}
+			if replies.String() != g.replies {
+				t.Errorf("replies = %q, want %q", replies.String(), g.replies)
+			}
+		})
+	}
+}
+
+func TestReflowCountsDroppedLines(t *testing.T) {
+	s := vt.NewScreen(10, 2)
+	s.SetScrollbackLimit(1)
+	s.Write([]byte("xxxxxxxxxx\r\nyyyyyyyyyy"))
+
+	// Both lines wrap in two: two rows go to the scrollback, which keeps one
+	s.Resize(5, 2)
+	if got := s.ScrollCount(); got != 2 {
+		t.Errorf("ScrollCount() = %d, want 2", got)
+	}
+	lines, first := s.NumberedViewport(1)
+	if first != 1 || lines[0].String() != "xxxxx" {
+		t.Errorf("NumberedViewport(1) starts at line %d with %q, want 1 with %q", first, lines[0].String(), "xxxxx")
+	}
+}
+
+func TestSpillWithoutScrollback(t *testing.T) {
+	s := vt.NewScreen(10, 2)
+	s.SetScrollbackLimit(0)
+	if err := s.SpillScrollback(); err != nil {
+		t.Fatal(err)
+	}
+	defer s.CloseSpill()
+	s.Write([]byte("one\r\ntwo\r\nthree"))
+
+	// Lines scrolled off the screen go straight to the spill file
+	if got := s.ScrollbackLen(); got != 1 {
+		t.Errorf("ScrollbackLen() = %d, want 1", got)
+	}
+	lines, first := s.NumberedViewport(1)
+	if first != 0 || lines[0].String() != "one" {
+		t.Errorf("NumberedViewport(1) starts at line %d with %q, want 0 with %q", first, lines[0].String(), "one")
+	}
+}
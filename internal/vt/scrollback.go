@@ -0,0 +1,81 @@
+package vt
+
+// scrollback is a ring of the lines scrolled off the top of the primary
+// screen, oldest first. Pushing a line is O(1) and, once the limit is
+// reached, replaces the oldest line, so memory stays bounded by the limit.
+type scrollback struct {
+	lines []Line // storage; grows up to limit, then used as a ring
+	head  int    // index of the oldest line once the ring is full
+	limit int
+}
+
+// Len returns the number of lines held
+func (sb *scrollback) Len() int {
+	return len(sb.lines)
+}
+
+// at returns the i-th line, counting from the oldest
+func (sb *scrollback) at(i int) *Line {
+	i += sb.head
+	if i >= len(sb.lines) {
+		i -= len(sb.lines)
+	}
+	return &sb.lines[i]
+}
+
+// push appends a line, dropping the oldest one when the scrollback is
+// full. The dropped line is returned so its cells can be reused; ok is
+// false when nothing was dropped.
+func (sb *scrollback) push(l Line) (dropped Line, ok bool) {
+	if sb.limit <= 0 {
+		return l, true
+	}
+	if len(sb.lines) < sb.limit {
+		sb.lines = append(sb.lines, l)
+		return Line{}, false
+	}
+	dropped = sb.lines[sb.head]
+	sb.lines[sb.head] = l
+	sb.head++
+	if sb.head == len(sb.lines) {
+		sb.head = 0
+	}
+	return dropped, true
+}
+
+// appendTo appends the lines from the i-th on to dst, oldest first, and
+// returns the extended slice. The lines share cells with the scrollback.
+func (sb *scrollback) appendTo(dst []Line, i int) []Line {
+	for ; i < len(sb.lines); i++ {
+		dst = append(dst, *sb.at(i))
+	}
+	return dst
+}
+
+// set replaces the contents with the last limit of lines and returns the
+// lines that did not fit
+func (sb *scrollback) set(lines []Line) (dropped []Line) {
+	if len(lines) > sb.limit {
+		dropped = lines[:len(lines)-sb.limit]
+		lines = lines[len(lines)-sb.limit:]
+	}
+	sb.lines = append(make([]Line, 0, len(lines)), lines...)
+	sb.head = 0
+	return dropped
+}
+
+// setLimit changes the maximum number of lines and returns the oldest
+// lines dropped to fit
+func (sb *scrollback) setLimit(n int) (dropped []Line) {
+	sb.limit = max(n, 0)
+	if len(sb.lines) > sb.limit || sb.head != 0 {
+		return sb.set(sb.appendTo(nil, 0))
+	}
+	return nil
+}
+
+// clear drops every line
+func (sb *scrollback) clear() {
+	sb.lines = nil
+	sb.head = 0
+}
@@ -0,0 +1,38 @@
+package vt_test
+
+import (
+	"bytes"
+	"terbox/internal/vt"
+	"testing"
+)
+
+// floodChunk is what `yes` writes to a terminal, read in pump-sized pieces
+var floodChunk = bytes.Repeat([]byte("y\r\n"), 32*1024/3)
+
+// benchmarkFlood writes floodChunk to a screen with 1000 lines of
+// scrollback, spilling older lines to a file if spill is set
+func benchmarkFlood(b *testing.B, spill bool) {
+	s := vt.NewScreen(80, 24)
+	s.SetScrollbackLimit(1000)
+	if spill {
+		if err := s.SpillScrollback(); err != nil {
+			b.Fatal(err)
+		}
+		defer s.CloseSpill()
+	}
+
+	b.SetBytes(int64(len(floodChunk)))
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		s.Write(floodChunk)
+	}
+}
+
+func BenchmarkScreenWriteFlood(b *testing.B) {
+	benchmarkFlood(b, false)
+}
+
+func BenchmarkScreenWriteFloodSpill(b *testing.B) {
+	benchmarkFlood(b, true)
+}
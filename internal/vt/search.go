@@ -0,0 +1,122 @@
+package vt
+
+import (
+	"regexp"
+	"strings"
+	"unicode/utf8"
+)
+
+// Match is a search result: a line, numbered as by NumberedViewport, and
+// the cells [Col, End) that matched
+type Match struct {
+	Line     uint64
+	Col, End int
+}
+
+// Search finds every match of re in the scrollback and on the screen,
+// oldest first. Lines are matched one at a time, without their trailing
+// blanks. On the alternate screen only the screen is searched, as the
+// scrollback cannot be shown there.
+func (s *Screen) Search(re *regexp.Regexp) []Match {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	sbLen := s.scrollbackLen()
+	first := s.scrollCount - uint64(sbLen)
+	start := 0
+	if s.modes&ModeAltScreen != 0 {
+		start = sbLen
+	}
+
+	var matches []Match
+	var text strings.Builder
+	var cols []int
+	for i := start; i < sbLen+len(s.lines); i++ {
+		cols = lineText(s.row(i), &text, cols[:0])
+		for _, m := range re.FindAllStringIndex(text.String(), -1) {
+			if m[0] == m[1] {
+				continue
+			}
+			matches = append(matches, Match{Line: first + uint64(i), Col: cols[m[0]], End: cols[m[1]]})
+		}
+	}
+	return matches
+}
+
+// lineText writes the text of a line to sb, blanks as spaces, and returns
+// cols extended with the column of each byte of the text, plus the column
+// after the end
+func lineText(l Line, sb *strings.Builder, cols []int) []int {
+	sb.Reset()
+	n := l.Len()
+	for x := 0; x < n; x++ {
+		c := l.Cells[x]
+		if c.Width == 0 {
+			continue
+		}
+		r := c.Rune
+		if r == 0 {
+			r = ' '
+		}
+		sb.WriteRune(r)
+		for range utf8.RuneLen(r) {
+			cols = append(cols, x)
+		}
+	}
+	return append(cols, n)
+}
+
+// LineOffset returns the scroll offset that shows a line, numbered as in
+// Match: offset itself if the line is in that view, else one that puts the
+// line in the middle of the view, or 0 on the live screen
+func (s *Screen) LineOffset(line uint64, offset int) int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.modes&ModeAltScreen != 0 {
+		return 0
+	}
+	top := s.scrollCount - uint64(offset)
+	if line >= top && line < top+uint64(s.height) {
+		return offset
+	}
+	if line >= s.scrollCount {
+		return 0
+	}
+	back := int(s.scrollCount - line)
+	return min(back+s.height/2, s.scrollbackLen())
+}
+
+// LineText returns the text of a line numbered as in Match, without
+// trailing blanks, or "" if the line is no longer kept
+func (s *Screen) LineText(line uint64) string {
+	l, _ := s.NumberedLine(line)
+	return l.String()
+}
+
+// NumberedLine returns a copy of a line numbered as in Match, and false if
+// the line is no longer kept
+func (s *Screen) NumberedLine(line uint64) (Line, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	first := s.scrollCount - uint64(s.scrollbackLen())
+	if line < first || line >= s.scrollCount+uint64(len(s.lines)) {
+		return Line{}, false
+	}
+	return s.row(int(line - first)).clone(), true
+}
+
+// LineRange returns the number of the oldest line kept and the number
+// after the last line of the screen. On the alternate screen only the
+// screen's lines can be shown, so the range covers just those.
+func (s *Screen) LineRange() (first, end uint64) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	end = s.scrollCount + uint64(len(s.lines))
+	if s.modes&ModeAltScreen != 0 {
+		return s.scrollCount, end
+	}
+	return s.scrollCount - uint64(s.scrollbackLen()), end
+}
@@ -0,0 +1,177 @@
+package vt
+
+import (
+	"strconv"
+	"strings"
+)
+
+// SGR returns the escape sequence that resets the rendition and selects style
+func (st Style) SGR() string {
+	var sb strings.Builder
+	sb.WriteString("\x1b[0")
+	for _, a := range []struct {
+		attr Attr
+		code string
+	}{
+		{AttrBold, "1"},
+		{AttrFaint, "2"},
+		{AttrItalic, "3"},
+		{AttrUnderline, "4"},
+		{AttrBlink, "5"},
+		{AttrReverse, "7"},
+		{AttrInvisible, "8"},
+		{AttrStrike, "9"},
+	} {
+		if st.Attr&a.attr != 0 {
+			sb.WriteByte(';')
+			sb.WriteString(a.code)
+		}
+	}
+	writeColorSGR(&sb, st.Fg, 30, 90, "38")
+	writeColorSGR(&sb, st.Bg, 40, 100, "48")
+	sb.WriteByte('m')
+	return sb.String()
+}
+
+// writeColorSGR appends the SGR parameters selecting c as a foreground
+// (base 30) or background (base 40) color
+func writeColorSGR(sb *strings.Builder, c Color, base, brightBase int, extended string) {
+	if idx, ok := c.Index(); ok {
+		sb.WriteByte(';')
+		switch {
+		case idx < 8:
+			sb.WriteString(strconv.Itoa(base + int(idx)))
+		case idx < 16:
+			sb.WriteString(strconv.Itoa(brightBase + int(idx) - 8))
+		default:
+			sb.WriteString(extended + ";5;" + strconv.Itoa(int(idx)))
+		}
+		return
+	}
+	if r, g, b, ok := c.RGB(); ok {
+		sb.WriteString(";" + extended + ";2;" +
+			strconv.Itoa(int(r)) + ";" + strconv.Itoa(int(g)) + ";" + strconv.Itoa(int(b)))
+	}
+}
+
+// selectGraphicRendition applies SGR parameters to the pen
+func (s *Screen) selectGraphicRendition(params [][]int) {
+	if len(params) == 0 {
+		s.cur.style = Style{}
+		return
+	}
+	st := &s.cur.style
+	for i := 0; i < len(params); i++ {
+		group := params[i]
+		switch p := group[0]; {
+		case p <= 0:
+			*st = Style{}
+		case p == 1:
+			st.Attr |= AttrBold
+		case p == 2:
+			st.Attr |= AttrFaint
+		case p == 3:
+			st.Attr |= AttrItalic
+		case p == 4:
+			// 4:0 turns underlining off, any other style turns it on
+			if len(group) > 1 && group[1] == 0 {
+				st.Attr &^= AttrUnderline
+			} else {
+				st.Attr |= AttrUnderline
+			}
+		case p == 5 || p == 6:
+			st.Attr |= AttrBlink
+		case p == 7:
+			st.Attr |= AttrReverse
+		case p == 8:
+			st.Attr |= AttrInvisible
+		case p == 9:
+			st.Attr |= AttrStrike
+		case p == 21:
+			st.Attr |= AttrUnderline
+		case p == 22:
+			st.Attr &^= AttrBold | AttrFaint
+		case p == 23:
+			st.Attr &^= AttrItalic
+		case p == 24:
+			st.Attr &^= AttrUnderline
+		case p == 25:
+			st.Attr &^= AttrBlink
+		case p == 27:
+			st.Attr &^= AttrReverse
+		case p == 28:
+			st.Attr &^= AttrInvisible
+		case p == 29:
+			st.Attr &^= AttrStrike
+		case p >= 30 && p <= 37:
+			st.Fg = IndexedColor(uint8(p - 30))
+		case p == 38:
+			var c Color
+			c, i = extendedColor(params, i)
+			st.Fg = c
+		case p == 39:
+			st.Fg = DefaultColor
+		case p >= 40 && p <= 47:
+			st.Bg = IndexedColor(uint8(p - 40))
+		case p == 48:
+			var c Color
+			c, i = extendedColor(params, i)
+			st.Bg = c
+		case p == 49:
+			st.Bg = DefaultColor
+		case p >= 90 && p <= 97:
+			st.Fg = IndexedColor(uint8(p - 90 + 8))
+		case p >= 100 && p <= 107:
+			st.Bg = IndexedColor(uint8(p - 100 + 8))
+		}
+	}
+}
+
+// extendedColor decodes a 38/48 color in either the colon form
+// (38:5:n, 38:2:cs:r:g:b) or the legacy semicolon form (38;5;n, 38;2;r;g;b).
+// It returns the color and the index of the last parameter consumed
+func extendedColor(params [][]int, i int) (Color, int) {
+	if sub := params[i][1:]; len(sub) > 0 {
+		switch sub[0] {
+		case 5:
+			if len(sub) >= 2 {
+				return IndexedColor(clampByte(sub[1])), i
+			}
+		case 2:
+			rgb := sub[1:]
+			if len(rgb) >= 4 {
+				// the first value is the color space id
+				rgb = rgb[1:]
+			}
+			if len(rgb) >= 3 {
+				return RGBColor(clampByte(rgb[0]), clampByte(rgb[1]), clampByte(rgb[2])), i
+			}
+		}
+		return DefaultColor, i
+	}
+
+	if i+1 >= len(params) {
+		return DefaultColor, i
+	}
+	switch params[i+1][0] {
+	case 5:
+		if i+2 < len(params) {
+			return IndexedColor(clampByte(params[i+2][0])), i + 2
+		}
+	case 2:
+		if i+4 < len(params) {
+			return RGBColor(clampByte(params[i+2][0]), clampByte(params[i+3][0]), clampByte(params[i+4][0])), i + 4
+		}
+	}
+	return DefaultColor, len(params)
+}
+
+func clampByte(v int) uint8 {
+	if v < 0 {
+		return 0
+	}
+	if v > 255 {
+		return 255
+	}
+	return uint8(v)
+}
@@ -0,0 +1,205 @@
+package vt
+
+import (
+	"bytes"
+	"compress/flate"
+	"encoding/binary"
+	"io"
+	"os"
+)
+
+// spillBlockLines is the number of lines compressed together in a spill
+// file. Reading any of them decompresses the whole block.
+const spillBlockLines = 256
+
+// spill holds the oldest scrollback lines, dropped from the in-memory
+// ring, compressed in a temporary file. Lines are encoded as they arrive
+// and written once a block is full.
+type spill struct {
+	file     *os.File
+	size     int64 // bytes written to the file
+	blocks   []spillBlock
+	pending  bytes.Buffer // encoded lines of the block being filled
+	npending int
+	zw       *flate.Writer
+	err      error // first write error; later lines are discarded
+
+	// The last block read, by index; len(blocks) is the pending one
+	cached int
+	cache  []Line
+}
+
+// spillBlock is the location of a compressed block in the file
+type spillBlock struct {
+	offset int64
+	size   int
+}
+
+// newSpill creates a spill with a new temporary file. The file is unlinked
+// at once, so nothing is left behind if the server is killed.
+func newSpill() (*spill, error) {
+	file, err := os.CreateTemp("", "terbox-scrollback-*")
+	if err != nil {
+		return nil, err
+	}
+	if err := os.Remove(file.Name()); err != nil {
+		file.Close()
+		return nil, err
+	}
+	return &spill{file: file, cached: -1}, nil
+}
+
+// Len returns the number of lines held
+func (sp *spill) Len() int {
+	return len(sp.blocks)*spillBlockLines + sp.npending
+}
+
+// push appends a line. Its cells are encoded at once and may be reused.
+func (sp *spill) push(l Line) {
+	if sp.err != nil {
+		return
+	}
+	encodeLine(&sp.pending, l)
+	sp.npending++
+	if sp.cached == len(sp.blocks) {
+		sp.cached = -1
+	}
+	if sp.npending == spillBlockLines {
+		sp.flush()
+	}
+}
+
+// flush compresses the pending block and writes it to the file
+func (sp *spill) flush() {
+	var buf bytes.Buffer
+	if sp.zw == nil {
+		sp.zw, _ = flate.NewWriter(&buf, flate.BestSpeed)
+	} else {
+		sp.zw.Reset(&buf)
+	}
+	sp.zw.Write(sp.pending.Bytes())
+	sp.zw.Close()
+
+	if sp.cached == len(sp.blocks) {
+		sp.cached = -1
+	}
+	sp.pending.Reset()
+	sp.npending = 0
+	if _, err := sp.file.WriteAt(buf.Bytes(), sp.size); err != nil {
+		// The block is lost and nothing more is kept
+		sp.err = err
+		return
+	}
+	sp.blocks = append(sp.blocks, spillBlock{offset: sp.size, size: buf.Len()})
+	sp.size += int64(buf.Len())
+}
+
+// line returns the i-th line, counting from the oldest. Trailing blank
+// cells are not stored, so the line may be shorter than the screen.
+func (sp *spill) line(i int) Line {
+	block := i / spillBlockLines
+	if block != sp.cached {
+		sp.cache = sp.load(block)
+		sp.cached = block
+	}
+	if j := i % spillBlockLines; j < len(sp.cache) {
+		return sp.cache[j]
+	}
+	return Line{}
+}
+
+// load decodes the lines of a block. A block that cannot be read comes
+// back empty, so its lines show blank.
+func (sp *spill) load(block int) []Line {
+	if block == len(sp.blocks) {
+		lines, _ := decodeLines(sp.pending.Bytes())
+		return lines
+	}
+	b := sp.blocks[block]
+	compressed := make([]byte, b.size)
+	if _, err := sp.file.ReadAt(compressed, b.offset); err != nil {
+		return nil
+	}
+	zr := flate.NewReader(bytes.NewReader(compressed))
+	defer zr.Close()
+	encoded, err := io.ReadAll(zr)
+	if err != nil {
+		return nil
+	}
+	lines, _ := decodeLines(encoded)
+	return lines
+}
+
+// clear drops every line and empties the file
+func (sp *spill) clear() {
+	sp.file.Truncate(0)
+	sp.size = 0
+	sp.blocks = nil
+	sp.pending.Reset()
+	sp.npending = 0
+	sp.cached, sp.cache = -1, nil
+	sp.err = nil
+}
+
+// close releases the file
+func (sp *spill) close() error {
+	return sp.file.Close()
+}
+
+// encodeLine appends a line to buf as a flags byte, the number of cells
+// and for each cell its rune, width, colors and attributes as varints.
+// Trailing blank cells are left out.
+func encodeLine(buf *bytes.Buffer, l Line) {
+	var flags byte
+	if l.Wrapped {
+		flags |= 1
+	}
+	if l.Prompt {
+		flags |= 2
+	}
+	n := len(l.Cells)
+	for n > 0 && l.Cells[n-1] == (Cell{Width: 1}) {
+		n--
+	}
+
+	b := buf.AvailableBuffer()
+	b = append(b, flags)
+	b = binary.AppendUvarint(b, uint64(n))
+	for _, c := range l.Cells[:n] {
+		b = binary.AppendUvarint(b, uint64(c.Rune))
+		b = append(b, c.Width)
+		b = binary.AppendUvarint(b, uint64(c.Fg))
+		b = binary.AppendUvarint(b, uint64(c.Bg))
+		b = binary.AppendUvarint(b, uint64(c.Attr))
+	}
+	buf.Write(b)
+}
+
+// decodeLines decodes the lines written by encodeLine
+func decodeLines(data []byte) ([]Line, error) {
+	r := bytes.NewReader(data)
+	var err error
+	uvarint := func() uint64 {
+		v, e := binary.ReadUvarint(r)
+		if err == nil {
+			err = e
+		}
+		return v
+	}
+
+	var lines []Line
+	for r.Len() > 0 && err == nil {
+		flags, _ := r.ReadByte()
+		l := Line{Wrapped: flags&1 != 0, Prompt: flags&2 != 0}
+		n := uvarint()
+		l.Cells = make([]Cell, 0, min(n, uint64(r.Len())))
+		for ; n > 0 && err == nil; n-- {
+			c := Cell{Rune: rune(uvarint())}
+			c.Width, _ = r.ReadByte()
+			c.Fg, c.Bg, c.Attr = Color(uvarint()), Color(uvarint()), Attr(uvarint())
+			l.Cells = append(l.Cells, c)
+		}
+		lines = append(lines, l)
+	}
+	return lines, err
+}
@@ -0,0 +1,84 @@
+package vt
+
+import (
+	"bytes"
+	"unicode/utf8"
+)
+
+// Pos is a cell: a line, numbered as in Match, and a column
+type Pos struct {
+	Line uint64
+	Col  int
+}
+
+// Text returns the text from one cell to another, both included, as it
+// should be copied: soft-wrapped lines are joined and trailing blanks are
+// dropped from each line. With block set the text is instead the columns
+// between the two cells on each line from one to the other, one line per
+// row.
+func (s *Screen) Text(from, to Pos, block bool) string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if to.Line < from.Line || to.Line == from.Line && to.Col < from.Col {
+		from, to = to, from
+	}
+	left, right := from.Col, to.Col
+	if block && left > right {
+		left, right = right, left
+	}
+
+	first := s.scrollCount - uint64(s.scrollbackLen())
+	end := s.scrollCount + uint64(len(s.lines))
+	if from.Line < first {
+		from = Pos{Line: first}
+	}
+	var text []byte
+	for line := from.Line; line <= to.Line && line < end; line++ {
+		l := s.row(int(line - first))
+		lo, hi := 0, len(l.Cells)-1
+		switch {
+		case block:
+			lo, hi = left, right
+		default:
+			if line == from.Line {
+				lo = from.Col
+			}
+			if line == to.Line {
+				hi = to.Col
+			}
+		}
+		text = appendCells(text, l, lo, hi)
+		if !block && l.Wrapped && line < to.Line {
+			continue
+		}
+		text = bytes.TrimRight(text, " ")
+		if line < to.Line {
+			text = append(text, '\n')
+		}
+	}
+	return string(text)
+}
+
+// appendCells appends the text of the cells [lo, hi] of a line to b, blanks
+// as spaces. A wide character is taken whole if either of its halves is in
+// range.
+func appendCells(b []byte, l Line, lo, hi int) []byte {
+	lo = max(lo, 0)
+	for lo > 0 && lo < len(l.Cells) && l.Cells[lo].Width == 0 {
+		lo--
+	}
+	hi = min(hi, len(l.Cells)-1)
+	for x := lo; x <= hi; x++ {
+		c := l.Cells[x]
+		switch {
+		case c.Width == 0:
+			// right half of a wide character
+		case c.Rune == 0:
+			b = append(b, ' ')
+		default:
+			b = utf8.AppendRune(b, c.Rune)
+		}
+	}
+	return b
+}
@@ -1,32 +1,108 @@
 package main
 
 import (
+	"errors"
 	"fmt"
-	"log"
 	"os"
+	"strings"
 
 	"terbox/internal/data"
-	"terbox/internal/ui"
-
-	tea "github.com/charmbracelet/bubbletea"
+	"terbox/internal/server"
 )
 
+const usage = `usage: terbox [command] [args...]
+
+commands:
+  (none)                              attach, starting the server if needed
+  attach                              attach to the running server
+  new [-n name] [-p profile] [-c dir] [-e K=V] [-u K] [-t title] [-l] [cmd...]
+                                      create a session and print its ID
+  ls [-json]                          list sessions
+  kill <session>                      close a session
+  rename <session> <name>             rename a session
+  send-keys [-l] <session> <keys>...  type keys ("enter", "ctrl+c") or text
+  capture-pane [-n lines] [-e | -json] [-J] <session>
+                                      print the screen and lines of scrollback
+  detach                              detach every attached client
+  kill-server                         close all sessions and stop the server
+  server                              run the server in the foreground
+
+Sessions are given by ID or name. Exit status is 0 on success, 1 when the
+command fails and 2 on invalid arguments.
+`
+
 func main() {
+	command := ""
+	if len(os.Args) > 1 {
+		command = os.Args[1]
+	}
+	path := server.SocketPath()
+
+	switch command {
+	case "", "attach":
+		exit, err := server.Attach(path, command == "")
+		if err != nil {
+			fail(err)
+		}
+		// Printed after the terminal is restored, like "[detached]" in tmux,
+		// followed by processes that had to be terminated on exit
+		status, report, _ := strings.Cut(exit.Message, "\n")
+		if status != "" {
+			fmt.Printf("[%s]\n", status)
+		}
+		if report != "" {
+			fmt.Fprintf(os.Stderr, "terbox: %s\n", report)
+		}
+		os.Exit(exit.Code)
+
+	case "new", "ls", "kill", "rename", "send-keys", "capture-pane", "detach", "kill-server":
+		// Only a new session is worth starting a server for
+		exit, err := server.Run(path, os.Stdout, command == "new", command, os.Args[2:]...)
+		if err != nil {
+			fail(err)
+		}
+		if exit.Message != "" {
+			fmt.Fprintf(os.Stderr, "terbox: %s\n", exit.Message)
+		}
+		os.Exit(exit.Code)
+
+	case "server":
+		runServer(path)
+
+	case "help", "-h", "--help":
+		fmt.Print(usage)
+
+	default:
+		fmt.Fprintf(os.Stderr, "terbox: unknown command %q\n%s", command, usage)
+		os.Exit(server.ExitUsage)
+	}
+}
+
+// runServer serves sessions on path until the server shuts down
+func runServer(path string) {
 	// Load configuration
 	config, err := data.LoadConfig()
 	if err != nil {
 		fmt.Fprintf(os.Stderr, "Error loading config: %v\n", err)
 		config = data.DefaultConfig()
 	}
+	// Invalid profiles are disabled rather than holding up the server;
+	// clients list them on the settings screen
+	if err := config.ValidateProfiles(); err != nil {
+		fmt.Fprintf(os.Stderr, "terbox: profiles disabled: %v\n", err)
+	}
 
-	// Create the application
-	app := ui.NewApp(config)
-
-	// Create Bubble Tea program
-	p := tea.NewProgram(app, tea.WithAltScreen(), tea.WithMouseCellMotion())
-
-	// Run the application
-	if _, err := p.Run(); err != nil {
-		log.Fatalf("Alas, there's been an error: %v", err)
+	s := server.New(config)
+	if err := s.Listen(path); err != nil {
+		fail(err)
+	}
+	if err := s.Serve(); err != nil && !errors.Is(err, os.ErrClosed) {
+		fail(err)
 	}
 }
+
+// fail reports an error and exits
+func fail(err error) {
+	fmt.Fprintf(os.Stderr, "terbox: %v\n", err)
+	os.Exit(server.ExitError)
+}